@@ -0,0 +1,57 @@
+package jsonpoly
+
+import (
+	"reflect"
+)
+
+// As returns the value stored in the container as type T. It handles both
+// values and pointers stored in the container, meaning that a container
+// holding a *Dog can be asserted as Dog and vice versa. If the value can not be
+// represented as T, ok is false.
+func As[T any, V any, H Helper[V]](c Container[V, H]) (T, bool) {
+	if t, ok := any(c.Value).(T); ok {
+		return t, true
+	}
+
+	var zero T
+	val := reflect.ValueOf(c.Value)
+	if !val.IsValid() {
+		return zero, false
+	}
+
+	want := reflect.TypeFor[T]()
+	switch {
+	case val.Kind() == reflect.Ptr && val.Type().Elem() == want:
+		// The container holds a pointer, dereference it.
+		if val.IsNil() {
+			return zero, false
+		}
+		return val.Elem().Interface().(T), true
+	case want.Kind() == reflect.Ptr && want.Elem() == val.Type():
+		// The container holds a value, return a pointer to a copy of it.
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		return ptr.Interface().(T), true
+	}
+	return zero, false
+}
+
+// Is reports whether the value stored in the container is of type T. Same as
+// with As, a container holding a *Dog is considered to be a Dog and vice
+// versa.
+func Is[T any, V any, H Helper[V]](c Container[V, H]) bool {
+	_, ok := As[T](c)
+	return ok
+}
+
+// ValueType returns the dynamic type of the value stored in the container,
+// e.g. to build dispatch tables or to log the Go type next to the
+// discriminator. If the container stores a pointer, the pointer type is
+// returned (e.g. *Dog), contrary to As and Is, which treat values and pointers
+// the same. If the value is nil or a nil pointer, ValueType returns nil.
+func (c Container[V, H]) ValueType() reflect.Type {
+	if isNil(c.Value) {
+		return nil
+	}
+	return reflect.TypeOf(c.Value)
+}
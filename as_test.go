@@ -0,0 +1,139 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAs(t *testing.T) {
+	dog := Dog{XName: "Fido", Breed: "Golden Retriever"}
+
+	t.Run("value", func(t *testing.T) {
+		c := New[Animal, *AnimalContainerHelper](dog)
+
+		if got, ok := As[Dog](c); !ok || got != dog {
+			t.Fatalf("want %v, got %v (ok=%v)", dog, got, ok)
+		}
+		if got, ok := As[*Dog](c); !ok || *got != dog {
+			t.Fatalf("want %v, got %v (ok=%v)", &dog, got, ok)
+		}
+		if got, ok := As[Cat](c); ok {
+			t.Fatalf("want no match, got %v", got)
+		}
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		c := New[Animal, *AnimalPtrContainerHelper](&dog)
+
+		if got, ok := As[Dog](c); !ok || got != dog {
+			t.Fatalf("want %v, got %v (ok=%v)", dog, got, ok)
+		}
+		if got, ok := As[*Dog](c); !ok || got != &dog {
+			t.Fatalf("want %v, got %v (ok=%v)", &dog, got, ok)
+		}
+		if got, ok := As[*Cat](c); ok {
+			t.Fatalf("want no match, got %v", got)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if got, ok := As[Dog](c); ok {
+			t.Fatalf("want no match, got %v", got)
+		}
+
+		c.Value = (*Dog)(nil)
+		if got, ok := As[Dog](c); ok {
+			t.Fatalf("want no match, got %v", got)
+		}
+	})
+}
+
+func TestIs(t *testing.T) {
+	dog := Dog{XName: "Fido"}
+
+	testCases := []struct {
+		name string
+		c    Container[Animal, *AnimalContainerHelper]
+	}{
+		{name: "value", c: New[Animal, *AnimalContainerHelper](dog)},
+		{name: "pointer", c: New[Animal, *AnimalContainerHelper](&dog)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !Is[Dog](tc.c) {
+				t.Fatal("expected Dog")
+			}
+			if !Is[*Dog](tc.c) {
+				t.Fatal("expected *Dog")
+			}
+			if !Is[Animal](tc.c) {
+				t.Fatal("expected Animal")
+			}
+			if Is[Cat](tc.c) || Is[*Cat](tc.c) {
+				t.Fatal("unexpected Cat")
+			}
+		})
+	}
+}
+
+func TestContainer_ValueType(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		fn   func([]byte) (reflect.Type, error)
+		want reflect.Type
+	}{{
+		name: "value",
+		have: `{"type":"dog","name":"Fido"}`,
+		fn: func(b []byte) (reflect.Type, error) {
+			var c Container[Animal, *AnimalContainerHelper]
+			err := json.Unmarshal(b, &c)
+			return c.ValueType(), err
+		},
+		want: reflect.TypeFor[Dog](),
+	}, {
+		name: "pointer",
+		have: `{"type":"cat","name":"Tom"}`,
+		fn: func(b []byte) (reflect.Type, error) {
+			var c Container[Animal, *AnimalPtrContainerHelper]
+			err := json.Unmarshal(b, &c)
+			return c.ValueType(), err
+		},
+		want: reflect.TypeFor[*Cat](),
+	}, {
+		name: "any",
+		have: `{"kind":"point","x":1,"y":2}`,
+		fn: func(b []byte) (reflect.Type, error) {
+			var c Container[any, *AnyHelper]
+			err := json.Unmarshal(b, &c)
+			return c.ValueType(), err
+		},
+		want: reflect.TypeFor[Point](),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.fn([]byte(tc.have))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if got := c.ValueType(); got != nil {
+			t.Fatalf("want nil, got %v", got)
+		}
+		c.Value = (*Dog)(nil)
+		if got := c.ValueType(); got != nil {
+			t.Fatalf("want nil, got %v", got)
+		}
+	})
+}
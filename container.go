@@ -1,22 +1,66 @@
 package jsonpoly
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"slices"
+	"strings"
 )
 
 var (
 	ErrNotJSONObject = errors.New("not a JSON object")
+	ErrUnknownType   = errors.New("unknown type")
+	ErrMissingType   = errors.New("missing type")
+	ErrTrailingData  = errors.New("trailing data after JSON object")
+	ErrDuplicateKey  = errors.New("duplicate key")
+	ErrLimitExceeded = errors.New("input exceeds size limit")
+
+	ErrMaxDepthExceeded     = errors.New("maximum nesting depth exceeded")
+	ErrHelperNotPointer     = errors.New("helper type must be a pointer")
+	ErrInvalidUnion         = errors.New("union must have exactly one non-nil pointer field")
+	ErrMarshalLimitExceeded = errors.New("output exceeds size limit")
+	ErrNoCandidate          = errors.New("no candidate type matches")
+	ErrTypeMismatch         = errors.New("type mismatch")
+	ErrTooManyMigrations    = errors.New("too many migrations")
+	ErrIDConflict           = errors.New("value has a non-empty field with the ID key")
 )
 
 // Container is a generic struct that can be used to unmarshal polymorphic JSON
 // objects into a specific type based on a key. It is using the Helper interface
 // to determine the type of the object and to create a new instance of the
 // unmarshalled object.
+//
+// V is usually an interface implemented by all types the container can hold,
+// but it can also be any, in which case Get can return values of unrelated
+// types (e.g. a struct for one discriminator and a map for another). Values
+// and pointers are both supported, regardless of V.
 type Container[V any, H Helper[V]] struct {
 	Value V
+
+	// ID is the identifier of the object (e.g. the id of a JSON:API resource
+	// or an event), it is only marshalled and unmarshalled if the helper sets
+	// Options.IDKey.
+	ID string
+
+	// raw contains the original input, if the helper enables
+	// Options.KeepRaw. It is stored as a string, so that the container stays
+	// comparable and can be used as a map key.
+	raw string
+}
+
+// New returns a Container holding the value v.
+func New[V any, H Helper[V]](v V) Container[V, H] {
+	return Container[V, H]{Value: v}
+}
+
+// NewPtr returns a pointer to a Container holding the value v. It is useful
+// when containers are stored in slices or maps and need to be addressable.
+func NewPtr[V any, H Helper[V]](v V) *Container[V, H] {
+	return &Container[V, H]{Value: v}
 }
 
 // Helper is an interface that must be implemented by the user to
@@ -24,73 +68,689 @@ type Container[V any, H Helper[V]] struct {
 // on the key. The struct implementing this interface should be a pointer type
 // and should contain public fields annotated with JSON tags that match the keys
 // in the JSON object.
+//
+// The JSON object is unmarshalled into the value returned by Get. If Get
+// returns a reference type (e.g. a pointer or a map), it should return a new
+// instance on every call, otherwise the shared instance is modified.
 type Helper[V any] interface {
 	Get() V
 	Set(V)
 }
 
+// ValueOwner is an optional interface a Helper can implement to declare keys
+// that are owned by the value. This is useful when the discriminator is not
+// separate metadata, but an existing field of the value. The keys returned by
+// ValueOwnedKeys are not emitted from the helper when marshalling, the value
+// is expected to marshal them itself.
+type ValueOwner interface {
+	ValueOwnedKeys() []string
+}
+
+// KeyOrderer is an optional interface a Helper can implement to control the
+// order of its keys when marshalling, independent of the order of the fields
+// in the helper struct. The keys returned by KeyOrder come first in the given
+// order, followed by any other keys of the helper in their original order.
+// The fields of the helper still come before the fields of the value.
+type KeyOrderer interface {
+	KeyOrder() []string
+}
+
+// PostMarshaler is an optional interface a Helper can implement to transform
+// the marshalled JSON object, after the fields of the helper and the value
+// were merged. PostMarshal is called on the helper populated by Set.
+type PostMarshaler interface {
+	PostMarshal(b []byte) ([]byte, error)
+}
+
+// PreUnmarshaler is an optional interface a Helper can implement to transform
+// the input before it is unmarshalled. PreUnmarshal is called on a zero
+// helper, the returned bytes are used to unmarshal both the helper and the
+// value.
+type PreUnmarshaler interface {
+	PreUnmarshal(b []byte) ([]byte, error)
+}
+
+// UnmarshalJSON unmarshals the JSON object into the helper to determine the
+// type of the value, and then unmarshals the same JSON object into the value
+// returned by the helper. Since the whole object is unmarshalled into the
+// value, a value that declares fields for the discriminator keys will have
+// them populated as well. If the input is null, c.Value is set to the zero
+// value, which mirrors MarshalJSON marshalling a nil value as null. If the
+// input is not a JSON object, ErrNotJSONObject is returned. A leading UTF-8
+// byte order mark is ignored, note that json.Unmarshal rejects it before
+// calling UnmarshalJSON.
+//
+// If the value implements Migrator, it is migrated to the current version
+// after it is unmarshalled. If the value implements Validator and the helper
+// enables Options.Validate, it is validated after it is unmarshalled and
+// migrated. On a validation failure c.Value is populated and a
+// *ValidationError is returned.
+//
+// A discriminator key with a null value is treated the same as a missing key,
+// the helper is left with the zero value for that field. If the helper does
+// not return a value, ErrMissingType is returned if none of the discriminator
+// keys are present, otherwise ErrUnknownType is returned.
 func (c *Container[V, H]) UnmarshalJSON(b []byte) error {
-	var helper H
-	if err := json.Unmarshal(b, &helper); err != nil {
-		return err
+	_, err := c.UnmarshalWithHelper(b)
+	return err
+}
+
+// UnmarshalWithHelper works the same as UnmarshalJSON, but it additionally
+// returns the helper used to determine the type of the value. This is useful
+// when the helper contains additional metadata (e.g. a version) that is not
+// needed to determine the type. The helper is returned even if the value could
+// not be unmarshalled, as long as the helper itself was unmarshalled.
+func (c *Container[V, H]) UnmarshalWithHelper(b []byte) (H, error) {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return helper, err
 	}
 
-	v := helper.Get()
+	res, err := decode[V](b, helper, false)
+	if res.valueDone {
+		c.Value, c.ID, c.raw = res.value, res.id, res.raw
+	}
+	if !res.helperDone {
+		var zero H
+		return zero, err
+	}
+	return helper, err
+}
 
-	// Check if the value is a pointer of a value. If it's a pointer, we use it
-	// as is. If it's a value, we create a pointer to it for the unmarshalling
-	// to work and store the underlying value in the 'Value' field.
-	val := reflect.ValueOf(v)
-	if !val.IsValid() {
-		// Apparently this is an unknown type, marshal the helper to represent
-		// the type and include it in the error message. We can safely ignore
-		// the error, since the type was already unmarshalled successfully.
-		b, _ := json.Marshal(helper)
-		return fmt.Errorf("unknown type %v", string(b))
-	}
-
-	var ptrVal reflect.Value
-	if val.Kind() != reflect.Ptr {
-		// Create a new pointer type based on the type of 'v'.
-		ptrType := reflect.PointerTo(val.Type())
-		// Allocate a new object of this pointer type.
-		ptrVal = reflect.New(ptrType.Elem())
-		// Set the newly allocated object to the value of 'v'.
-		ptrVal.Elem().Set(val)
-		// Now 'ptrVal' is a reflect.Value of type '*V' which can be used as a pointer.
-		v = ptrVal.Interface().(V)
-	}
-
-	if err := json.Unmarshal(b, v); err != nil {
-		return err
+// UnmarshalWithPresence works the same as UnmarshalJSON, but it additionally
+// reports whether the discriminator was explicitly present in the input, as
+// opposed to the helper falling back to a default type. The discriminator is
+// considered present if any of the keys of the helper are found in the input
+// with a value other than null. If the helper is not unmarshalled (e.g.
+// because of Options.Untyped or Options.IgnoreTypeOnUnmarshal), present is
+// false. The information is returned instead of stored in the container, so
+// that decoded containers stay equal to containers holding the same value.
+func (c *Container[V, H]) UnmarshalWithPresence(b []byte) (present bool, err error) {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return false, err
 	}
 
-	if ptrVal.IsValid() {
-		// If we used a pointer, we need to get the underlying value.
-		c.Value = ptrVal.Elem().Interface().(V)
-	} else {
-		// If we used the value directly, we store it in the 'Value' field.
-		c.Value = v
+	res, err := decode[V](b, helper, true)
+	if res.valueDone {
+		c.Value, c.ID, c.raw = res.value, res.id, res.raw
 	}
+	return res.present, err
+}
 
-	return nil
+// utf8BOM is the byte order mark some editors write at the start of UTF-8
+// files. It is not valid JSON, so it is stripped before decoding.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// decodeResult is the result of decode.
+type decodeResult[V any] struct {
+	// value is the decoded value, id the identifier and raw the input to
+	// store in the container, they are only valid if valueDone is true.
+	value V
+	id    string
+	raw   string
+
+	// present is true if any of the keys of the helper were found in the
+	// input, it is only valid if helperDone is true and the presence was
+	// requested.
+	present bool
+
+	// helperDone is true if the helper was unmarshalled (or skipped because
+	// of the options).
+	helperDone bool
+	// valueDone is true if the value should be stored, even if an error was
+	// returned (e.g. a validation error).
+	valueDone bool
+}
+
+// decode unmarshals the JSON object into the zero helper and the value
+// returned by it. The presence of the discriminator is only reported if
+// wantPresence is true.
+func decode[V any](b []byte, helper Helper[V], wantPresence bool) (decodeResult[V], error) {
+	opts := optionsOf(helper)
+	res, err := decodeObject(b, helper, opts, wantPresence)
+	if opts.Observer != nil {
+		opts.Observer(OpUnmarshal, observedType(helper), err)
+	}
+	return res, err
+}
+
+// decodeObject does the work of decode.
+func decodeObject[V any](b []byte, helper Helper[V], opts Options, wantPresence bool) (decodeResult[V], error) {
+	var res decodeResult[V]
+	var err error
+
+	b = bytes.TrimPrefix(b, utf8BOM)
+	if opts.JSONC {
+		if b, err = stripJSONC(b); err != nil {
+			return res, err
+		}
+	}
+	// The stored input is valid JSON, so it can be marshalled as it is.
+	input := b
+	if p, ok := helper.(PreUnmarshaler); ok {
+		if b, err = p.PreUnmarshal(b); err != nil {
+			return res, err
+		}
+	}
+
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		// Same as encoding/json does for pointers and interfaces, null
+		// results in the zero value. The helper is skipped.
+		res.helperDone, res.valueDone = true, true
+		return res, nil
+	}
+	if !isJSONObject(b) {
+		return res, ErrNotJSONObject
+	}
+
+	if opts.NormalizeKeys != nil {
+		if b, err = normalizeKeys(b, opts.NormalizeKeys); err != nil {
+			return res, err
+		}
+	}
+	if opts.MaxDepth > 0 {
+		if err := checkDepth(b, opts.MaxDepth); err != nil {
+			return res, err
+		}
+	}
+	if opts.RejectDuplicateKeys {
+		if err := checkDuplicateKeys(b); err != nil {
+			return res, err
+		}
+	}
+	if opts.RejectAnyDuplicateKeys {
+		if err := checkAllDuplicateKeys(b, "$"); err != nil {
+			return res, err
+		}
+	}
+
+	if !opts.IgnoreTypeOnUnmarshal && !opts.Untyped {
+		if res.present, err = unmarshalHelper(b, helper, wantPresence); err != nil {
+			return res, err
+		}
+	}
+	res.helperDone = true
+
+	if opts.IDKey != "" {
+		if res.id, b, err = extractID(b, opts.IDKey); err != nil {
+			return res, err
+		}
+	}
+
+	v, err := unmarshalValue[V](b, helper, opts)
+	if err != nil && !errors.Is(err, ErrUnknownType) && !errors.Is(err, ErrMissingType) {
+		v, err = repairValue(b, err, helper, opts)
+	}
+	if err != nil {
+		if errors.Is(err, ErrUnknownType) && opts.OnUnknown != OnUnknownError {
+			res.valueDone = true
+			if opts.OnUnknown == OnUnknownCapture {
+				res.raw = string(input)
+			}
+			return res, nil
+		}
+		return res, err
+	}
+	v, err = migrate(v)
+	if err != nil {
+		return res, err
+	}
+	// The value is stored even if it fails validation, so it's available
+	// for inspection.
+	res.value, res.valueDone = v, true
+	if opts.KeepRaw {
+		res.raw = string(input)
+	}
+	return res, validate(v, opts)
 }
 
+// MarshalJSON marshals the helper and the value and merges both JSON objects
+// into a single object. The fields of the helper come first, followed by the
+// fields of the value. Within each object the fields keep the order in which
+// they were produced by the marshaller (struct field order for structs, sorted
+// keys for maps, and whatever order a custom json.Marshaler emits), the merge
+// never reorders them. If the value contains a key that is also produced by
+// the helper (e.g. the value declares the discriminator field), the key is
+// only emitted once, in the position and with the value produced by the
+// helper.
+//
+// If the helper produces an empty object (e.g. because it has no fields),
+// ErrMissingType is returned, since the output could not be unmarshalled.
+// Use Options.Untyped for values without a discriminator.
+//
+// If the value is nil or a nil pointer, the container is marshalled as null,
+// unless it holds an object of an unknown type captured because of
+// OnUnknownCapture, which is returned verbatim. If the helper enables
+// Options.MarshalRaw and the container holds the original input (see Raw),
+// the input is returned verbatim, the same applies to
+// Options.MarshalRawUnchanged if the value was not changed. If the output is
+// larger than Options.MaxMarshalBytes, ErrMarshalLimitExceeded is returned.
 func (c Container[V, H]) MarshalJSON() ([]byte, error) {
-	helper := reflect.New(reflect.TypeFor[H]().Elem()).Interface().(H)
-	helper.Set(c.Value)
+	if isNil(c.Value) && c.raw == "" {
+		return []byte("null"), nil
+	}
+
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return nil, err
+	}
+	opts := optionsOf(helper)
+
+	b, err := c.marshal(helper, opts)
+	if err == nil && opts.MarshalRawUnchanged && c.raw != "" {
+		b, err = c.rawIfUnchanged(b, opts)
+	}
+	if err == nil && opts.MaxMarshalBytes > 0 && len(b) > opts.MaxMarshalBytes {
+		err = fmt.Errorf("%w: %d bytes, limit is %d bytes", ErrMarshalLimitExceeded, len(b), opts.MaxMarshalBytes)
+	}
+	if opts.Observer != nil {
+		opts.Observer(OpMarshal, observedType(helper), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// rawIfUnchanged returns the stored input if the value marshals into b, the
+// same as the value unmarshalled from the stored input. Otherwise b is
+// returned.
+func (c Container[V, H]) rawIfUnchanged(b []byte, opts Options) ([]byte, error) {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return nil, err
+	}
+	res, err := decodeObject([]byte(c.raw), helper, opts, false)
+	if err != nil || !res.valueDone {
+		// The stored input can't be decoded anymore, so the value must have
+		// been set separately.
+		return b, nil
+	}
+
+	orig := Container[V, H]{Value: res.value, ID: res.id}
+	if helper, err = newHelper[V, H](); err != nil {
+		return nil, err
+	}
+	origBytes, err := orig.marshal(helper, opts)
+	if err != nil || !bytes.Equal(b, origBytes) {
+		return b, nil
+	}
+	return []byte(c.raw), nil
+}
+
+// marshal marshals the container using the newly allocated helper, see
+// MarshalJSON.
+func (c Container[V, H]) marshal(helper H, opts Options) ([]byte, error) {
+	switch {
+	case isNil(c.Value) && opts.OnUnknown == OnUnknownCapture:
+		// The container holds a captured object of an unknown type.
+		return []byte(c.raw), nil
+	case isNil(c.Value):
+		return []byte("null"), nil
+	case opts.MarshalRaw && c.raw != "":
+		return []byte(c.raw), nil
+	}
+
+	jsonValue, err := marshalValue(c.Value, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SortKeys && isJSONObject(jsonValue) {
+		jsonValue, err = sortKeys(jsonValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	envelope := opts.Envelope
+	if !envelope && opts.AutoEnvelope {
+		if envelope, err = needsEnvelope(jsonValue, dataKey(helper)); err != nil {
+			return nil, err
+		}
+	}
+	if envelope {
+		jsonValue, err = writeObject([]objectField{{key: dataKey(helper), value: jsonValue}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.IDKey != "" && isJSONObject(jsonValue) {
+		if err := checkOwnID(jsonValue, opts.IDKey); err != nil {
+			return nil, err
+		}
+	}
+	if opts.IDKey != "" && c.ID != "" {
+		// The ID is added in front of the value, so it comes right after the
+		// fields of the helper.
+		if jsonValue, err = prependID(jsonValue, opts.IDKey, c.ID); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Untyped {
+		return postMarshal(helper, jsonValue, opts)
+	}
 
+	jsonHelper, err := marshalHelper(helper, c.Value)
+	if err != nil {
+		return nil, err
+	}
+	b, err := mergeJSONObjects(jsonHelper, jsonValue)
+	if err != nil {
+		return nil, err
+	}
+	return postMarshal(helper, b, opts)
+}
+
+// marshalHelper sets the value in the helper and returns the fields of the
+// helper as they are emitted in the output of MarshalJSON.
+func marshalHelper[V any](helper Helper[V], v V) ([]byte, error) {
+	helper.Set(v)
 	jsonHelper, err := json.Marshal(helper)
 	if err != nil {
 		return nil, err
 	}
+	if isEmptyObject(jsonHelper) {
+		// The output could not be unmarshalled, since it would not contain
+		// a discriminator.
+		return nil, fmt.Errorf("%w: helper %T produced no fields, use Options.Untyped for values without a discriminator", ErrMissingType, helper)
+	}
+	if o, ok := any(helper).(ValueOwner); ok {
+		jsonHelper, err = removeKeys(jsonHelper, o.ValueOwnedKeys())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m, ok := any(helper).(DiscriminatorMapper); ok {
+		jsonHelper, err = mapStringFields(jsonHelper, func(key, value string) (string, error) {
+			return m.ToWire(key, value), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o, ok := any(helper).(KeyOrderer); ok {
+		jsonHelper, err = orderKeys(jsonHelper, o.KeyOrder())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jsonHelper, nil
+}
+
+// needsEnvelope reports whether the marshalled value needs to be stored in an
+// envelope with the given key because of Options.AutoEnvelope, i.e. if it is
+// not a JSON object or if it contains the key.
+func needsEnvelope(b []byte, key string) (bool, error) {
+	if !isJSONObject(b) {
+		return true, nil
+	}
+	_, ok, err := findField(b, key)
+	return ok, err
+}
+
+// extractID returns the identifier stored under key in the JSON object and
+// the object without the key, so the identifier is not unmarshalled into the
+// value.
+func extractID(b []byte, key string) (string, []byte, error) {
+	raw, ok, err := findField(b, key)
+	if err != nil || !ok {
+		return "", b, err
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return "", nil, fmt.Errorf("id %q is not a string: %w", key, err)
+	}
+	if b, err = omitFields(b, []string{key}); err != nil {
+		return "", nil, err
+	}
+	return id, b, nil
+}
+
+// checkOwnID returns ErrIDConflict if the JSON object of the value has a
+// field with the ID key that is not empty. Keys are matched
+// case-insensitively. The field would be read into Container.ID when
+// unmarshalling, so its value would be lost.
+func checkOwnID(b []byte, key string) error {
+	keys := []string{key}
+	return scanObject(b, func(k, v []byte) error {
+		if !containsKeyFold(keys, k) {
+			return nil
+		}
+		if s := string(v); s != `""` && s != "null" {
+			return fmt.Errorf("%w %q", ErrIDConflict, key)
+		}
+		return nil
+	})
+}
+
+// prependID adds the identifier under key to the start of the JSON object. If
+// the object already contains the key in any casing, it is replaced.
+func prependID(b []byte, key, id string) ([]byte, error) {
+	raw, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	o, err := writeObject([]objectField{{key: key, value: raw}})
+	if err != nil {
+		return nil, err
+	}
+	return mergeJSONObjects(o, b)
+}
+
+// postMarshal passes the marshalled object to the helper if it implements
+// PostMarshaler and applies the escaping options to the result.
+func postMarshal(helper any, b []byte, opts Options) ([]byte, error) {
+	if p, ok := helper.(PostMarshaler); ok {
+		var err error
+		if b, err = p.PostMarshal(b); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DisableHTMLEscape || opts.EscapeNonASCII {
+		b = escapeStrings(b, opts.DisableHTMLEscape, opts.EscapeNonASCII)
+	}
+	return b, nil
+}
 
-	jsonValue, err := json.Marshal(c.Value)
+// Raw returns the original JSON input the container was unmarshalled from.
+// The input is only stored if the helper enables Options.KeepRaw, or if the
+// type was unknown and the helper sets Options.OnUnknown to
+// OnUnknownCapture, otherwise Raw returns nil.
+func (c Container[V, H]) Raw() json.RawMessage {
+	if c.raw == "" {
+		return nil
+	}
+	return json.RawMessage(c.raw)
+}
+
+// MarshalValue marshals only the value, without the fields produced by the
+// helper. The output is the same as the value part of MarshalJSON, including
+// marshalling a nil value as null.
+func (c Container[V, H]) MarshalValue() ([]byte, error) {
+	if isNil(c.Value) {
+		return []byte("null"), nil
+	}
+
+	helper, err := newHelper[V, H]()
 	if err != nil {
 		return nil, err
 	}
+	return marshalValue(c.Value, optionsOf(helper))
+}
+
+// marshalValue marshals the value. If the value is a union, only the selected
+// variant is marshalled.
+func marshalValue(v any, opts Options) ([]byte, error) {
+	if opts.Union {
+		variant, err := unionVariant(reflect.ValueOf(v))
+		if err != nil {
+			return nil, err
+		}
+		if !variant.IsValid() {
+			return nil, ErrInvalidUnion
+		}
+		v = variant.Interface()
+	}
+	if opts.Codec != nil {
+		return opts.Codec.Marshal(v)
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalValue unmarshals the JSON object into the value returned by the
+// already populated helper.
+func unmarshalValue[V any](b []byte, helper Helper[V], opts Options) (V, error) {
+	var zero V
+	v := defaults(helper.Get(), helper)
+
+	// The object is used to build the error message, while the data is
+	// unmarshalled into the value. The data is the object itself, unless the
+	// value is wrapped in an envelope.
+	// With AutoEnvelope, the object is an envelope only if it contains the
+	// data key.
+	obj, b := b, b
+	envelope := opts.Envelope
+	if opts.Envelope || opts.AutoEnvelope {
+		data, ok, err := findField(obj, dataKey(helper))
+		if err != nil {
+			return zero, err
+		}
+		if !ok {
+			data = []byte("null")
+		}
+		if envelope = envelope || ok; envelope {
+			b = data
+		}
+	}
+	if !envelope && opts.TrimDiscriminator {
+		keys, err := discriminatorKeys(helper)
+		if err != nil {
+			return zero, err
+		}
+		if b, err = omitFields(b, keys); err != nil {
+			return zero, err
+		}
+	}
+
+	// A nil pointer is treated the same as an untyped nil, there is nothing
+	// to unmarshal into.
+	if isNil(v) {
+		return zero, unknownTypeError(obj, helper)
+	}
+	val := reflect.ValueOf(v)
+
+	if opts.Union {
+		// The value is a union, we only unmarshal into the selected variant.
+		// The variant is a pointer, so the union value already references it.
+		variant, err := unionVariant(val)
+		if err != nil {
+			return zero, err
+		}
+		if !variant.IsValid() {
+			return zero, unknownTypeError(obj, helper)
+		}
+		if err := unmarshalInto(b, variant.Interface(), helper, opts); err != nil {
+			release(helper, v)
+			return zero, err
+		}
+		return v, nil
+	}
+
+	// Check if the value is a pointer of a value. If it's a pointer, we use it
+	// as is. If it's a value, we create a pointer to it for the unmarshalling
+	// to work and return the underlying value.
+	if val.Kind() == reflect.Ptr {
+		if err := unmarshalInto(b, v, helper, opts); err != nil {
+			release(helper, v)
+			return zero, err
+		}
+		return v, nil
+	}
+
+	// Create a new pointer type based on the type of 'v'.
+	ptrType := reflect.PointerTo(val.Type())
+	// Allocate a new object of this pointer type.
+	ptrVal := reflect.New(ptrType.Elem())
+	// Set the newly allocated object to the value of 'v'.
+	ptrVal.Elem().Set(val)
+	// Now 'ptrVal' is a reflect.Value of type '*T' which can be used as a
+	// pointer. Note that '*T' does not necessarily implement V, e.g. if V is a
+	// concrete type, so we don't convert it to V.
+	if err := unmarshalInto(b, ptrVal.Interface(), helper, opts); err != nil {
+		return zero, err
+	}
+	// We used a pointer, we need to get the underlying value.
+	return ptrVal.Elem().Interface().(V), nil
+}
+
+// unmarshalInto unmarshals the JSON object into v, which needs to be a
+// pointer. If v implements TypedUnmarshaler, it is used instead of the
+// options.
+func unmarshalInto(b []byte, v any, helper any, opts Options) error {
+	if u, ok := v.(TypedUnmarshaler); ok {
+		// The discriminator is rendered the same as for Options.Observer.
+		return u.UnmarshalJSONWithType(b, observedType(helper))
+	}
+	if opts.Codec != nil {
+		return opts.Codec.Unmarshal(b, v)
+	}
+	if !opts.UseNumber {
+		return json.Unmarshal(b, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// unknownTypeError returns the error for a helper that did not return a
+// value. The helper is marshalled to represent the type in the error message.
+func unknownTypeError(b []byte, helper any) error {
+	// We can safely ignore the error, since the helper was already
+	// unmarshalled successfully.
+	h, _ := json.Marshal(helper)
+	if !hasDiscriminator(b, h) {
+		return ErrMissingType
+	}
+	return fmt.Errorf("%w %v", ErrUnknownType, string(h))
+}
+
+// unionVariant returns the only non-nil exported pointer field of the union
+// struct in val. If no field is set, the returned value is invalid. If more
+// than one field is set, ErrInvalidUnion is returned.
+func unionVariant(val reflect.Value) (reflect.Value, error) {
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, ErrInvalidUnion
+	}
 
-	return mergeJSONObjects(jsonHelper, jsonValue)
+	var variant reflect.Value
+	for i := 0; i < val.NumField(); i++ {
+		f := val.Field(i)
+		if !val.Type().Field(i).IsExported() || f.Kind() != reflect.Ptr || f.IsNil() {
+			continue
+		}
+		if variant.IsValid() {
+			return reflect.Value{}, ErrInvalidUnion
+		}
+		variant = f
+	}
+	return variant, nil
+}
+
+// isNil reports whether v is nil or a nil pointer.
+func isNil(v any) bool {
+	val := reflect.ValueOf(v)
+	return !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil())
 }
 
 func mergeJSONObjects(o1, o2 []byte) ([]byte, error) {
@@ -98,11 +758,80 @@ func mergeJSONObjects(o1, o2 []byte) ([]byte, error) {
 		return nil, ErrNotJSONObject
 	}
 
-	// We know this is only used internally, we can manipulate the slices.
-	// We append the second object to the first one, replacing the closing
-	// object bracket with a comma.
-	o2[0] = ','
-	return append(o1[:len(o1)-1], o2...), nil
+	fields1, err := parseObject(o1)
+	if err != nil {
+		return nil, err
+	}
+	fields2, err := parseObject(o2)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip fields in the second object that are already present in the first
+	// one, otherwise the merged object would contain duplicate keys. Keys are
+	// compared case-insensitively, the same as when unmarshalling, so a
+	// field like "Type" can't override the discriminator "type".
+	keys := make(map[string]bool, len(fields1))
+	for _, f := range fields1 {
+		keys[strings.ToLower(f.key)] = true
+	}
+	for _, f := range fields2 {
+		if !keys[strings.ToLower(f.key)] {
+			fields1 = append(fields1, f)
+		}
+	}
+
+	return writeObject(fields1)
+}
+
+// orderKeys reorders the fields of the JSON object, so that the given keys
+// come first in the given order, followed by the remaining fields.
+func orderKeys(o []byte, keys []string) ([]byte, error) {
+	fields, err := parseObject(o)
+	if err != nil {
+		return nil, err
+	}
+	rank := func(key string) int {
+		if i := slices.Index(keys, key); i >= 0 {
+			return i
+		}
+		return len(keys)
+	}
+	slices.SortStableFunc(fields, func(a, b objectField) int {
+		return rank(a.key) - rank(b.key)
+	})
+	return writeObject(fields)
+}
+
+// removeKeys removes the keys from the JSON object.
+func removeKeys(o []byte, keys []string) ([]byte, error) {
+	fields, err := parseObject(o)
+	if err != nil {
+		return nil, err
+	}
+	fields = slices.DeleteFunc(fields, func(f objectField) bool {
+		return slices.Contains(keys, f.key)
+	})
+	return writeObject(fields)
+}
+
+// checkDuplicateKeys returns ErrDuplicateKey if the JSON object contains the
+// same key more than once. Keys are compared case-insensitively.
+func checkDuplicateKeys(o []byte) error {
+	fields, err := parseObject(o)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		key := strings.ToLower(f.key)
+		if keys[key] {
+			return fmt.Errorf("%w %q", ErrDuplicateKey, f.key)
+		}
+		keys[key] = true
+	}
+	return nil
 }
 
 func isJSONObject(o []byte) bool {
@@ -111,3 +840,35 @@ func isJSONObject(o []byte) bool {
 	}
 	return o[0] == '{' && o[len(o)-1] == '}'
 }
+
+// isEmptyObject reports whether o is a JSON object without any fields.
+func isEmptyObject(o []byte) bool {
+	o = bytes.TrimSpace(o)
+	if !isJSONObject(o) {
+		return false
+	}
+	return len(bytes.TrimSpace(o[1:len(o)-1])) == 0
+}
+
+// hasDiscriminator reports whether any key of the helper object is present in
+// the input object with a non-null value. Keys are matched case-insensitively,
+// the same as when unmarshalling into a struct.
+func hasDiscriminator(input, helper []byte) bool {
+	helperFields, err := parseObject(helper)
+	if err != nil {
+		return true
+	}
+	inputFields, err := parseObject(input)
+	if err != nil {
+		return true
+	}
+
+	for _, hf := range helperFields {
+		for _, f := range inputFields {
+			if strings.EqualFold(hf.key, f.key) && string(f.value) != "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
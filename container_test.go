@@ -2,8 +2,11 @@ package jsonpoly
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -37,6 +40,84 @@ func (c Cat) Name() string {
 	return c.XName
 }
 
+// Bird declares the discriminator field, so it gets populated when
+// unmarshalling.
+type Bird struct {
+	XType string `json:"type"`
+	XName string `json:"name"`
+}
+
+func (Bird) Type() string {
+	return "bird"
+}
+func (b Bird) Name() string {
+	return b.XName
+}
+
+// OwnedDog contains a nested polymorphic value.
+type OwnedDog struct {
+	XName string                                    `json:"name"`
+	Owner Container[Person, *PersonContainerHelper] `json:"owner"`
+}
+
+func (OwnedDog) Type() string {
+	return "owned-dog"
+}
+func (d OwnedDog) Name() string {
+	return d.XName
+}
+
+// Fish implements custom marshalling that flattens the wrapped details.
+type Fish struct {
+	XName   string
+	Details FishDetails
+}
+
+type FishDetails struct {
+	Water string
+}
+
+func (Fish) Type() string {
+	return "fish"
+}
+func (f Fish) Name() string {
+	return f.XName
+}
+
+func (f Fish) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"name":  f.XName,
+		"water": f.Details.Water,
+	})
+}
+
+func (f *Fish) UnmarshalJSON(b []byte) error {
+	var flat struct {
+		Name  string `json:"name"`
+		Water string `json:"water"`
+	}
+	if err := json.Unmarshal(b, &flat); err != nil {
+		return err
+	}
+	f.XName = flat.Name
+	f.Details.Water = flat.Water
+	return nil
+}
+
+// Plankton marshals into an empty object.
+type Plankton struct{}
+
+func (Plankton) Type() string {
+	return "plankton"
+}
+func (Plankton) Name() string {
+	return ""
+}
+
+func (Plankton) MarshalJSON() ([]byte, error) {
+	return []byte("{ }"), nil
+}
+
 type UnknownAnimal struct {
 	XType string `json:"-"`
 	XName string `json:"name"`
@@ -52,8 +133,11 @@ func (a UnknownAnimal) Name() string {
 
 var (
 	KnownAnimals = map[string]Animal{
-		"dog": Dog{},
-		"cat": Cat{},
+		"dog":       Dog{},
+		"cat":       Cat{},
+		"bird":      Bird{},
+		"owned-dog": OwnedDog{},
+		"fish":      Fish{},
 	}
 )
 
@@ -72,14 +156,41 @@ func (h *AnimalContainerHelper) Set(a Animal) {
 	h.Type = a.Type()
 }
 
+type Person interface {
+	Role() string
+}
+
+type Breeder struct {
+	XName  string `json:"name"`
+	Kennel string `json:"kennel"`
+}
+
+func (Breeder) Role() string {
+	return "breeder"
+}
+
+type PersonContainerHelper struct {
+	Role string `json:"role"`
+}
+
+func (h *PersonContainerHelper) Get() Person {
+	if h.Role == "breeder" {
+		return Breeder{}
+	}
+	return nil
+}
+
+func (h *PersonContainerHelper) Set(p Person) {
+	h.Role = p.Role()
+}
+
 func ExampleContainer_marshal() {
 	dog := Dog{
 		XName: "Fido",
 		Breed: "Golden Retriever",
 	}
 
-	var c Container[Animal, *AnimalContainerHelper]
-	c.Value = dog
+	c := New[Animal, *AnimalContainerHelper](dog)
 
 	raw, err := json.Marshal(c)
 	if err != nil {
@@ -265,3 +376,1557 @@ func TestContainer_pointer(t *testing.T) {
 		})
 	}
 }
+
+// macawFields is embedded in Macaw, so Macaw can have an untagged field
+// named Type next to the Type method.
+type macawFields struct {
+	Type string
+}
+
+// Macaw declares the discriminator in an untagged field, which is
+// marshalled under the key "Type".
+type Macaw struct {
+	macawFields
+	XName string `json:"name"`
+}
+
+func (Macaw) Type() string {
+	return "macaw"
+}
+func (m Macaw) Name() string {
+	return m.XName
+}
+
+type MacawAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *MacawAnimalHelper) Get() Animal {
+	if h.Type == "macaw" {
+		return Macaw{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func TestContainer_discriminatorInValueOtherCase(t *testing.T) {
+	t.Run("round_trip", func(t *testing.T) {
+		have := Macaw{macawFields: macawFields{Type: "macaw"}, XName: "Polly"}
+		testRoundTrip[Animal, *MacawAnimalHelper](t, have, `{"type":"macaw","name":"Polly"}`)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		// The empty field of the value must not override the
+		// discriminator.
+		got, err := json.Marshal(Container[Animal, *MacawAnimalHelper]{Value: Macaw{XName: "Polly"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"macaw","name":"Polly"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+		var c Container[Animal, *MacawAnimalHelper]
+		if err := json.Unmarshal(got, &c); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := c.Value.(Macaw); !ok {
+			t.Fatalf("want Macaw, got %T", c.Value)
+		}
+	})
+}
+
+func TestContainer_discriminatorInValue(t *testing.T) {
+	raw := `{"type":"bird","name":"Tweety"}`
+
+	var c Container[Animal, *AnimalContainerHelper]
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Bird{XType: "bird", XName: "Tweety"}
+	if c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != raw {
+		t.Fatalf("want %s, got %s", raw, string(got))
+	}
+}
+
+func TestContainer_nested(t *testing.T) {
+	have := OwnedDog{
+		XName: "Fido",
+		Owner: Container[Person, *PersonContainerHelper]{
+			Value: Breeder{XName: "Alice", Kennel: "Happy Paws"},
+		},
+	}
+	want := `{"type":"owned-dog","name":"Fido","owner":{"role":"breeder","name":"Alice","kennel":"Happy Paws"}}`
+
+	got, err := json.Marshal(Container[Animal, *AnimalContainerHelper]{Value: have})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, string(got))
+	}
+
+	var c Container[Animal, *AnimalContainerHelper]
+	if err := json.Unmarshal([]byte(want), &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Value, have) {
+		t.Fatalf("want %v, got %v", have, c.Value)
+	}
+}
+
+func TestContainer_customMarshaler(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{
+		{
+			name: "fish",
+			have: Fish{
+				XName:   "Nemo",
+				Details: FishDetails{Water: "salt"},
+			},
+			want: `{"type":"fish","name":"Nemo","water":"salt"}`,
+		},
+		{
+			name: "plankton",
+			have: Plankton{},
+			want: `{"type":"plankton"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(Container[Animal, *AnimalContainerHelper]{Value: tc.have})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, string(got))
+			}
+		})
+	}
+
+	t.Run("fish_unmarshal", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		err := json.Unmarshal([]byte(`{"type":"fish","name":"Nemo","water":"salt"}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Fish{XName: "Nemo", Details: FishDetails{Water: "salt"}}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+}
+
+func TestContainer_notJSONObject(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+	}{
+		{name: "string", have: `"dog"`},
+		{name: "number", have: `1`},
+		{name: "array", have: `[{"type":"dog"}]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[Animal, *AnimalContainerHelper]
+			err := json.Unmarshal([]byte(tc.have), &c)
+			if !errors.Is(err, ErrNotJSONObject) {
+				t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+			}
+		})
+	}
+}
+
+func TestContainer_null(t *testing.T) {
+	for _, have := range []string{`null`, ` null `} {
+		c := New[Animal, *AnimalContainerHelper](Dog{XName: "Fido"})
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != nil {
+			t.Fatalf("want nil, got %v", c.Value)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var cs []Container[Animal, *AnimalContainerHelper]
+		if err := json.Unmarshal([]byte(`[{"type":"dog","name":"Fido"},null]`), &cs); err != nil {
+			t.Fatal(err)
+		}
+		want := []Container[Animal, *AnimalContainerHelper]{{Value: Dog{XName: "Fido"}}, {}}
+		if !reflect.DeepEqual(cs, want) {
+			t.Fatalf("want %v, got %v", want, cs)
+		}
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		v, err := Unmarshal[Animal, *AnimalContainerHelper]([]byte(`null`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != nil {
+			t.Fatalf("want nil, got %v", v)
+		}
+	})
+}
+
+func TestContainer_bom(t *testing.T) {
+	const have = "\xEF\xBB\xBF" + `{"type":"dog","name":"Fido"}`
+	want := Dog{XName: "Fido"}
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		var c Container[Animal, *RawKeepingAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(c.Raw()), have[3:]; got != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("bom_only", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalJSON([]byte("\xEF\xBB\xBF")); !errors.Is(err, ErrNotJSONObject) {
+			t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+		}
+	})
+}
+
+// AnimalAtTypeHelper uses a discriminator key with a special character.
+type AnimalAtTypeHelper struct {
+	Type string `json:"@type"`
+}
+
+func (h *AnimalAtTypeHelper) Get() Animal {
+	return (&AnimalContainerHelper{Type: h.Type}).Get()
+}
+
+func (h *AnimalAtTypeHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+// AnimalDottedHelper uses a discriminator key containing a dot.
+type AnimalDottedHelper struct {
+	Type string `json:"x.type"`
+}
+
+func (h *AnimalDottedHelper) Get() Animal {
+	return (&AnimalContainerHelper{Type: h.Type}).Get()
+}
+
+func (h *AnimalDottedHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestContainer_specialKeys(t *testing.T) {
+	have := Dog{XName: "Fido", Breed: "Golden Retriever"}
+
+	t.Run("at", func(t *testing.T) {
+		want := `{"@type":"dog","name":"Fido","breed":"Golden Retriever"}`
+		testRoundTrip[Animal, *AnimalAtTypeHelper](t, have, want)
+	})
+	t.Run("dot", func(t *testing.T) {
+		want := `{"x.type":"dog","name":"Fido","breed":"Golden Retriever"}`
+		testRoundTrip[Animal, *AnimalDottedHelper](t, have, want)
+	})
+}
+
+// testRoundTrip marshals the value in a container, compares it to the wanted
+// JSON and unmarshals it back, comparing the result to the original value.
+func testRoundTrip[V any, H Helper[V]](t *testing.T, have V, want string) {
+	t.Helper()
+
+	got, err := json.Marshal(Container[V, H]{Value: have})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, string(got))
+	}
+
+	var c Container[V, H]
+	if err := json.Unmarshal(got, &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Value, have) {
+		t.Fatalf("want %v, got %v", have, c.Value)
+	}
+}
+
+// LazyAnimal captures the raw JSON object without decoding it.
+type LazyAnimal struct {
+	XType string
+	Raw   json.RawMessage
+}
+
+func (a LazyAnimal) Type() string {
+	return a.XType
+}
+func (LazyAnimal) Name() string {
+	return ""
+}
+
+func (a LazyAnimal) MarshalJSON() ([]byte, error) {
+	return a.Raw, nil
+}
+
+func (a *LazyAnimal) UnmarshalJSON(b []byte) error {
+	a.Raw = append(a.Raw[:0], b...)
+	return nil
+}
+
+// AnimalLazyHelper returns a LazyAnimal for every type.
+type AnimalLazyHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AnimalLazyHelper) Get() Animal {
+	return LazyAnimal{XType: h.Type}
+}
+
+func (h *AnimalLazyHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestContainer_rawValue(t *testing.T) {
+	raw := `{"type":"dog","name":"Fido","breed":"Golden Retriever"}`
+
+	var c Container[Animal, *AnimalLazyHelper]
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := c.Value.(LazyAnimal)
+	if !ok {
+		t.Fatalf("want %T, got %T", LazyAnimal{}, c.Value)
+	}
+	if got.XType != "dog" {
+		t.Fatalf("want type %q, got %q", "dog", got.XType)
+	}
+	if string(got.Raw) != raw {
+		t.Fatalf("want %s, got %s", raw, string(got.Raw))
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != raw {
+		t.Fatalf("want %s, got %s", raw, string(b))
+	}
+}
+
+func TestNew(t *testing.T) {
+	dog := Dog{XName: "Fido"}
+
+	c := New[Animal, *AnimalContainerHelper](dog)
+	if c.Value != dog {
+		t.Fatalf("want %v, got %v", dog, c.Value)
+	}
+
+	ptr := NewPtr[Animal, *AnimalContainerHelper](dog)
+	if ptr.Value != dog {
+		t.Fatalf("want %v, got %v", dog, ptr.Value)
+	}
+}
+
+func TestContainer_nullDiscriminator(t *testing.T) {
+	t.Run("fallback", func(t *testing.T) {
+		for _, raw := range []string{
+			`{"type":null,"name":"Cooper"}`,
+			`{"name":"Cooper"}`,
+		} {
+			var c Container[Animal, *AnimalContainerHelper]
+			if err := json.Unmarshal([]byte(raw), &c); err != nil {
+				t.Fatal(err)
+			}
+
+			want := UnknownAnimal{XName: "Cooper"}
+			if c.Value != want {
+				t.Fatalf("want %v, got %v", want, c.Value)
+			}
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testCases := []struct {
+			have    string
+			wantErr error
+		}{
+			{have: `{"role":null,"name":"Alice"}`, wantErr: ErrMissingType},
+			{have: `{"name":"Alice"}`, wantErr: ErrMissingType},
+			{have: `{"role":"vet","name":"Alice"}`, wantErr: ErrUnknownType},
+		}
+
+		for _, tc := range testCases {
+			var c Container[Person, *PersonContainerHelper]
+			err := json.Unmarshal([]byte(tc.have), &c)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("%s: want %v, got %v", tc.have, tc.wantErr, err)
+			}
+		}
+	})
+}
+
+func TestContainer_nilValue(t *testing.T) {
+	for _, v := range []Animal{nil, (*Dog)(nil)} {
+		got, err := json.Marshal(Container[Animal, *AnimalContainerHelper]{Value: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "null" {
+			t.Fatalf("want null, got %s", string(got))
+		}
+	}
+}
+
+func TestContainer_optionalPointer(t *testing.T) {
+	type Owner struct {
+		Name string                                     `json:"name"`
+		Pet  *Container[Animal, *AnimalContainerHelper] `json:"pet,omitempty"`
+	}
+
+	testCases := []struct {
+		name string
+		have Owner
+		want string
+	}{
+		{
+			name: "absent",
+			have: Owner{Name: "Alice"},
+			want: `{"name":"Alice"}`,
+		},
+		{
+			name: "present",
+			have: Owner{
+				Name: "Alice",
+				Pet:  NewPtr[Animal, *AnimalContainerHelper](Dog{XName: "Fido"}),
+			},
+			want: `{"name":"Alice","pet":{"type":"dog","name":"Fido","breed":""}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(tc.have)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, string(got))
+			}
+
+			var o Owner
+			if err := json.Unmarshal(got, &o); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(o, tc.have) {
+				t.Fatalf("want %v, got %v", tc.have, o)
+			}
+		})
+	}
+}
+
+// AnimalValueHelper is a misused helper, since it is not a pointer type.
+type AnimalValueHelper struct {
+	Type string `json:"type"`
+}
+
+func (h AnimalValueHelper) Get() Animal {
+	return KnownAnimals[h.Type]
+}
+
+func (h AnimalValueHelper) Set(Animal) {}
+
+func TestContainer_helperNotPointer(t *testing.T) {
+	c := New[Animal, AnimalValueHelper](Dog{XName: "Fido"})
+
+	_, err := json.Marshal(c)
+	if !errors.Is(err, ErrHelperNotPointer) {
+		t.Fatalf("want %v, got %v", ErrHelperNotPointer, err)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":"dog"}`), &c)
+	if !errors.Is(err, ErrHelperNotPointer) {
+		t.Fatalf("want %v, got %v", ErrHelperNotPointer, err)
+	}
+}
+
+func TestContainer_trailingData(t *testing.T) {
+	for _, raw := range []string{
+		`{"type":"dog","name":"Fido"} extra`,
+		`{"type":"dog","name":"Fido"} {"type":"cat"}`,
+	} {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := json.Unmarshal([]byte(raw), &c); err == nil {
+			t.Fatalf("%s: expected error from json.Unmarshal", raw)
+		}
+		if err := c.UnmarshalJSON([]byte(raw)); err == nil {
+			t.Fatalf("%s: expected error from UnmarshalJSON", raw)
+		}
+	}
+}
+
+// DogBreedHelper uses the breed of the dog as the discriminator, the breed is
+// owned by the value.
+type DogBreedHelper struct {
+	Breed string `json:"breed"`
+}
+
+func (h *DogBreedHelper) Get() Animal {
+	if h.Breed == "" {
+		return nil
+	}
+	return Dog{}
+}
+
+func (h *DogBreedHelper) Set(a Animal) {
+	h.Breed = a.(Dog).Breed
+}
+
+func (h *DogBreedHelper) ValueOwnedKeys() []string {
+	return []string{"breed"}
+}
+
+func TestContainer_valueOwnedKeys(t *testing.T) {
+	have := Dog{XName: "Fido", Breed: "Golden Retriever"}
+	want := `{"name":"Fido","breed":"Golden Retriever"}`
+	testRoundTrip[Animal, *DogBreedHelper](t, have, want)
+}
+
+func BenchmarkContainer_UnmarshalJSON(b *testing.B) {
+	raw := []byte(`{"type":"cat","name":"Whiskers","owner":"Alice","color":"White","details":` + strings.Repeat(`{"nested":[1,2,3,"four"],"blob":`, 500) + `null` + strings.Repeat(`}`, 500) + `}`)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalJSON(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// OrderedAnimal marshals its attributes in insertion order, like an ordered
+// map would.
+type OrderedAnimal struct {
+	Keys   []string
+	Values []string
+}
+
+func (OrderedAnimal) Type() string {
+	return "ordered"
+}
+func (OrderedAnimal) Name() string {
+	return ""
+}
+
+func (a OrderedAnimal) MarshalJSON() ([]byte, error) {
+	fields := make([]objectField, len(a.Keys))
+	for i := range a.Keys {
+		v, err := json.Marshal(a.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = objectField{key: a.Keys[i], value: v}
+	}
+	return writeObject(fields)
+}
+
+func TestContainer_preservesOrder(t *testing.T) {
+	have := OrderedAnimal{
+		Keys:   []string{"zebra", "apple", "mango", "banana"},
+		Values: []string{"1", "2", "3", "4"},
+	}
+	want := `{"type":"ordered","zebra":"1","apple":"2","mango":"3","banana":"4"}`
+
+	got, err := json.Marshal(New[Animal, *AnimalContainerHelper](have))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, string(got))
+	}
+}
+
+// MapHelper is used for dynamic values stored in maps.
+type MapHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *MapHelper) Get() map[string]any {
+	return map[string]any{}
+}
+
+func (h *MapHelper) Set(m map[string]any) {
+	h.Type, _ = m["kind"].(string)
+}
+
+func TestContainer_mapValue(t *testing.T) {
+	t.Run("marshal", func(t *testing.T) {
+		// The map contains the key "type" which collides with the
+		// discriminator, the value set by the helper takes precedence.
+		have := map[string]any{"type": "ignored", "kind": "event", "b": 1, "a": "x"}
+		want := `{"type":"event","a":"x","b":1,"kind":"event"}`
+
+		got, err := json.Marshal(New[map[string]any, *MapHelper](have))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("want %s, got %s", want, string(got))
+		}
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var c Container[map[string]any, *MapHelper]
+		err := json.Unmarshal([]byte(`{"type":"event","kind":"event","a":"x"}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]any{"type": "event", "kind": "event", "a": "x"}
+		if !reflect.DeepEqual(c.Value, want) {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+}
+
+// AnimalNilHelper returns typed and untyped nil values for unknown types.
+type AnimalNilHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AnimalNilHelper) Get() Animal {
+	switch h.Type {
+	case "dog":
+		return &Dog{}
+	case "ghost":
+		return (*Dog)(nil)
+	}
+	return nil
+}
+
+func (h *AnimalNilHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestContainer_nilFromGet(t *testing.T) {
+	for _, raw := range []string{
+		`{"type":"ghost","name":"Casper"}`,
+		`{"type":"dolphin","name":"Cooper"}`,
+	} {
+		var c Container[Animal, *AnimalNilHelper]
+		err := json.Unmarshal([]byte(raw), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("%s: want %v, got %v", raw, ErrUnknownType, err)
+		}
+	}
+}
+
+// VersionedAnimalHelper carries metadata that is not needed to determine the
+// type.
+type VersionedAnimalHelper struct {
+	AnimalContainerHelper
+	Version int `json:"version"`
+}
+
+func TestContainer_UnmarshalWithHelper(t *testing.T) {
+	raw := `{"type":"dog","version":2,"name":"Fido","breed":"Golden Retriever"}`
+
+	var c Container[Animal, *VersionedAnimalHelper]
+	helper, err := c.UnmarshalWithHelper([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Dog{XName: "Fido", Breed: "Golden Retriever"}
+	if c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+	if helper.Type != "dog" || helper.Version != 2 {
+		t.Fatalf("unexpected helper %+v", helper)
+	}
+
+	// The helper is returned even if the value can not be unmarshalled.
+	helper, err = c.UnmarshalWithHelper([]byte(`{"type":"dog","version":3,"name":1}`))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if helper.Type != "dog" || helper.Version != 3 {
+		t.Fatalf("unexpected helper %+v", helper)
+	}
+}
+
+func TestContainer_MarshalValue(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{
+		{
+			name: "value",
+			have: Dog{XName: "Fido", Breed: "Golden Retriever"},
+			want: `{"name":"Fido","breed":"Golden Retriever"}`,
+		},
+		{
+			name: "pointer",
+			have: &Dog{XName: "Fido", Breed: "Golden Retriever"},
+			want: `{"name":"Fido","breed":"Golden Retriever"}`,
+		},
+		{
+			name: "nil",
+			have: nil,
+			want: `null`,
+		},
+		{
+			name: "nil_pointer",
+			have: (*Dog)(nil),
+			want: `null`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := New[Animal, *AnimalContainerHelper](tc.have).MarshalValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, string(got))
+			}
+		})
+	}
+}
+
+// RegistryRefHelper holds a reference to a registry, which needs to be set
+// before the helper is used.
+type RegistryRefHelper struct {
+	Type string `json:"type"`
+
+	registry *Registry[Animal]
+}
+
+func (h *RegistryRefHelper) Init() {
+	h.registry = animalRegistry
+}
+
+func (h *RegistryRefHelper) Get() Animal {
+	a, _ := h.registry.Lookup(h.Type)
+	return a
+}
+
+func (h *RegistryRefHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestContainer_helperInitializer(t *testing.T) {
+	testRoundTrip[Animal, *RegistryRefHelper](
+		t,
+		Dog{XName: "Fido", Breed: "Pug"},
+		`{"type":"dog","name":"Fido","breed":"Pug"}`,
+	)
+
+	var c Container[Animal, *RegistryRefHelper]
+	err := json.Unmarshal([]byte(`{"type":"bird"}`), &c)
+	if !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("want %v, got %v", ErrUnknownType, err)
+	}
+}
+
+// AnyHelper determines the type of a value stored as any. The returned types
+// do not share any methods.
+type AnyHelper struct {
+	Kind string `json:"kind"`
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Label struct {
+	Text string `json:"text"`
+}
+
+func (h *AnyHelper) Get() any {
+	switch h.Kind {
+	case "point":
+		return Point{}
+	case "point-ptr":
+		return &Point{}
+	case "label":
+		return Label{}
+	case "map":
+		return map[string]any{}
+	}
+	return nil
+}
+
+func (h *AnyHelper) Set(v any) {
+	switch v.(type) {
+	case Point:
+		h.Kind = "point"
+	case *Point:
+		h.Kind = "point-ptr"
+	case Label:
+		h.Kind = "label"
+	case map[string]any:
+		h.Kind = "map"
+	}
+}
+
+func TestContainer_any(t *testing.T) {
+	testCases := []struct {
+		name string
+		have any
+		want string
+	}{{
+		name: "value",
+		have: Point{X: 1, Y: 2},
+		want: `{"kind":"point","x":1,"y":2}`,
+	}, {
+		name: "pointer",
+		have: &Point{X: 1, Y: 2},
+		want: `{"kind":"point-ptr","x":1,"y":2}`,
+	}, {
+		name: "other_value",
+		have: Label{Text: "hello"},
+		want: `{"kind":"label","text":"hello"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[any, *AnyHelper](t, tc.have, tc.want)
+		})
+	}
+
+	t.Run("map", func(t *testing.T) {
+		var c Container[any, *AnyHelper]
+		if err := json.Unmarshal([]byte(`{"kind":"map","a":1}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{"kind": "map", "a": float64(1)}
+		if !reflect.DeepEqual(c.Value, want) {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("as", func(t *testing.T) {
+		var c Container[any, *AnyHelper]
+		if err := json.Unmarshal([]byte(`{"kind":"point-ptr","x":1,"y":2}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		p, ok := As[Point](c)
+		if !ok || p != (Point{X: 1, Y: 2}) {
+			t.Fatalf("want point, got %v (ok=%v)", p, ok)
+		}
+		if Is[Label](c) {
+			t.Fatal("expected value not to be a label")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		var c Container[any, *AnyHelper]
+		err := json.Unmarshal([]byte(`{"kind":"circle"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+		err = json.Unmarshal([]byte(`{"x":1}`), &c)
+		if !errors.Is(err, ErrMissingType) {
+			t.Fatalf("want %v, got %v", ErrMissingType, err)
+		}
+	})
+}
+
+// DimensionFirstHelper emits the dimension before the kind, although the
+// struct declares them the other way around.
+type DimensionFirstHelper struct {
+	Kind      string `json:"kind"`
+	Dimension int    `json:"dimension"`
+	Extra     string `json:"extra,omitempty"`
+}
+
+func (h *DimensionFirstHelper) KeyOrder() []string {
+	return []string{"dimension", "kind"}
+}
+
+func (h *DimensionFirstHelper) Get() map[string]any {
+	return map[string]any{}
+}
+
+func (h *DimensionFirstHelper) Set(m map[string]any) {
+	h.Kind, _ = m["k"].(string)
+	h.Dimension, _ = m["d"].(int)
+	h.Extra, _ = m["e"].(string)
+}
+
+func TestContainer_KeyOrderer(t *testing.T) {
+	testCases := []struct {
+		name string
+		have map[string]any
+		want string
+	}{{
+		name: "ordered",
+		have: map[string]any{"k": "cube", "d": 3},
+		want: `{"dimension":3,"kind":"cube","d":3,"k":"cube"}`,
+	}, {
+		name: "unordered_keys_last",
+		have: map[string]any{"k": "cube", "d": 3, "e": "x"},
+		want: `{"dimension":3,"kind":"cube","extra":"x","d":3,"e":"x","k":"cube"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(New[map[string]any, *DimensionFirstHelper](tc.have))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// NestedTypeHelper reads the discriminator from a nested object, while the
+// fields of the value are at the top level.
+type NestedTypeHelper struct {
+	Payload struct {
+		Kind string `json:"kind"`
+	} `json:"payload"`
+}
+
+func (h *NestedTypeHelper) Get() Animal {
+	return KnownAnimals[h.Payload.Kind]
+}
+
+func (h *NestedTypeHelper) Set(a Animal) {
+	h.Payload.Kind = a.Type()
+}
+
+func TestContainer_nestedDiscriminator(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "dog",
+		have: Dog{XName: "Fido", Breed: "Pug"},
+		want: `{"payload":{"kind":"dog"},"name":"Fido","breed":"Pug"}`,
+	}, {
+		name: "cat",
+		have: Cat{XName: "Tom", Color: "black"},
+		want: `{"payload":{"kind":"cat"},"name":"Tom","owner":"","color":"black"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Animal, *NestedTypeHelper](t, tc.have, tc.want)
+		})
+	}
+
+	t.Run("field_order", func(t *testing.T) {
+		var c Container[Animal, *NestedTypeHelper]
+		err := json.Unmarshal([]byte(`{"name":"Fido","payload":{"id":1,"kind":"dog"}}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Dog{XName: "Fido"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		var c Container[Animal, *NestedTypeHelper]
+		err := json.Unmarshal([]byte(`{"payload":{"kind":"dolphin"},"name":"Fido"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+		err = json.Unmarshal([]byte(`{"payload":{},"name":"Fido"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+	})
+}
+
+// Coordinate is used as a map key, it is marshalled as text.
+type Coordinate struct {
+	X, Y int
+}
+
+func (c Coordinate) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d:%d", c.X, c.Y)), nil
+}
+
+func (c *Coordinate) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d:%d", &c.X, &c.Y)
+	return err
+}
+
+// Hedgehog has fields with maps with non-string keys.
+type Hedgehog struct {
+	XName     string                `json:"name"`
+	Spines    map[int]string        `json:"spines"`
+	Territory map[Coordinate]string `json:"territory"`
+	Visits    map[uint8]bool        `json:"visits,omitempty"`
+}
+
+func (Hedgehog) Type() string {
+	return "hedgehog"
+}
+func (h Hedgehog) Name() string {
+	return h.XName
+}
+
+type HedgehogHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *HedgehogHelper) Get() Animal {
+	if h.Type == "hedgehog" {
+		return Hedgehog{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func TestContainer_nonStringMapKeys(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Hedgehog
+		want string
+	}{{
+		name: "int_keys",
+		have: Hedgehog{XName: "Sonic", Spines: map[int]string{2: "b", -1: "a", 10: "c"}},
+		want: `{"type":"hedgehog","name":"Sonic","spines":{"-1":"a","10":"c","2":"b"},"territory":null}`,
+	}, {
+		name: "text_marshaler_keys",
+		have: Hedgehog{XName: "Sonic", Territory: map[Coordinate]string{{X: 1, Y: 2}: "nest", {X: -3, Y: 0}: "food"}},
+		want: `{"type":"hedgehog","name":"Sonic","spines":null,"territory":{"-3:0":"food","1:2":"nest"}}`,
+	}, {
+		name: "uint_keys",
+		have: Hedgehog{XName: "Sonic", Visits: map[uint8]bool{255: true, 0: false}},
+		want: `{"type":"hedgehog","name":"Sonic","spines":null,"territory":null,"visits":{"0":false,"255":true}}`,
+	}, {
+		name: "empty_maps",
+		have: Hedgehog{XName: "Sonic", Spines: map[int]string{}, Territory: map[Coordinate]string{}},
+		want: `{"type":"hedgehog","name":"Sonic","spines":{},"territory":{}}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Animal, *HedgehogHelper](t, tc.have, tc.want)
+		})
+	}
+}
+
+// Egg has only optional fields.
+type Egg struct {
+	XName string `json:"name,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+func (Egg) Type() string {
+	return "egg"
+}
+func (e Egg) Name() string {
+	return e.XName
+}
+
+type EggHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *EggHelper) Get() Animal {
+	if h.Type == "egg" {
+		return Egg{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func TestContainer_emptyValues(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "empty_struct",
+		have: Egg{},
+		want: `{"type":"egg"}`,
+	}, {
+		name: "pointer_to_empty_struct",
+		have: &Egg{},
+		want: `{"type":"egg"}`,
+	}, {
+		name: "empty_object_marshaler",
+		have: Plankton{},
+		want: `{"type":"plankton"}`,
+	}, {
+		name: "nil_pointer",
+		have: (*Egg)(nil),
+		want: `null`,
+	}, {
+		name: "nil",
+		have: nil,
+		want: `null`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(New[Animal, *EggHelper](tc.have))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var c Container[Animal, *EggHelper]
+		if err := json.Unmarshal([]byte(`{"type":"egg"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != (Egg{}) {
+			t.Fatalf("want empty egg, got %v", c.Value)
+		}
+	})
+}
+
+// HookedAnimalHelper adds a field with the schema version when marshalling
+// and removes it before unmarshalling, so values never see it.
+type HookedAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *HookedAnimalHelper) PostMarshal(b []byte) ([]byte, error) {
+	if h.Type != "dog" {
+		return b, nil
+	}
+	// Only dogs are versioned.
+	return mergeJSONObjects(b, []byte(`{"schema":"v1"}`))
+}
+
+func (h *HookedAnimalHelper) PreUnmarshal(b []byte) ([]byte, error) {
+	schema, ok, err := findField(b, "schema")
+	if err != nil || !ok {
+		return b, err
+	}
+	if string(schema) != `"v1"` {
+		return nil, fmt.Errorf("unsupported schema %s", schema)
+	}
+	return removeKeys(b, []string{"schema"})
+}
+
+func TestContainer_hooks(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "dog",
+		have: Dog{XName: "Fido"},
+		want: `{"type":"dog","name":"Fido","breed":"","schema":"v1"}`,
+	}, {
+		name: "cat",
+		have: Cat{XName: "Tom"},
+		want: `{"type":"cat","name":"Tom","owner":"","color":""}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Animal, *HookedAnimalHelper](t, tc.have, tc.want)
+		})
+	}
+
+	t.Run("pre_unmarshal_error", func(t *testing.T) {
+		var c Container[Animal, *HookedAnimalHelper]
+		err := json.Unmarshal([]byte(`{"type":"dog","schema":"v2"}`), &c)
+		if err == nil || !strings.Contains(err.Error(), "unsupported schema") {
+			t.Fatalf("expected unsupported schema error, got %v", err)
+		}
+	})
+}
+
+// DefaultDogHelper falls back to a Dog if the discriminator is missing.
+type DefaultDogHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *DefaultDogHelper) Get() Animal {
+	if h.Type == "" {
+		return Dog{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+// CustomDefaultDogHelper is the same as DefaultDogHelper, but implements
+// custom unmarshalling, so its keys are not statically known.
+type CustomDefaultDogHelper struct {
+	DefaultDogHelper
+}
+
+func (h *CustomDefaultDogHelper) UnmarshalJSON(b []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	h.Type, _ = m["type"].(string)
+	return nil
+}
+
+// UnmarshalOnlyDogHelper can't be marshalled, unmarshalling must not need
+// the keys of the helper unless the presence is requested.
+type UnmarshalOnlyDogHelper struct {
+	CustomDefaultDogHelper
+}
+
+func (h *UnmarshalOnlyDogHelper) MarshalJSON() ([]byte, error) {
+	panic("helper marshalled")
+}
+
+func TestContainer_UnmarshalJSON_noPresence(t *testing.T) {
+	var c Container[Animal, *UnmarshalOnlyDogHelper]
+	if err := json.Unmarshal([]byte(`{"type":"dog","name":"Fido"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Dog{XName: "Fido"}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+}
+
+func TestContainer_UnmarshalWithPresence(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		want bool
+	}{{
+		name: "present",
+		have: `{"type":"dog","name":"Fido"}`,
+		want: true,
+	}, {
+		name: "present_empty",
+		have: `{"type":"","name":"Fido"}`,
+		want: true,
+	}, {
+		name: "present_case_insensitive",
+		have: `{"TYPE":"dog","name":"Fido"}`,
+		want: true,
+	}, {
+		// A null discriminator is treated as missing, the default type
+		// is used.
+		name: "null",
+		have: `{"type":null,"name":"Fido"}`,
+		want: false,
+	}, {
+		name: "absent",
+		have: `{"name":"Fido"}`,
+		want: false,
+	}, {
+		name: "absent_nested",
+		have: `{"name":"Fido","owner":{"type":"breeder"}}`,
+		want: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c1 Container[Animal, *DefaultDogHelper]
+			got, err := c1.UnmarshalWithPresence([]byte(tc.have))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+
+			var c2 Container[Animal, *CustomDefaultDogHelper]
+			got, err = c2.UnmarshalWithPresence([]byte(tc.have))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("custom helper: want %v, got %v", tc.want, got)
+			}
+			if _, ok := c2.Value.(Dog); !ok {
+				t.Fatalf("want Dog, got %T", c2.Value)
+			}
+		})
+	}
+
+	t.Run("untyped", func(t *testing.T) {
+		var c Container[Animal, *FixedDogHelper]
+		present, err := c.UnmarshalWithPresence([]byte(`{"type":"dog"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if present {
+			t.Fatal("want false when the helper is not unmarshalled")
+		}
+	})
+}
+
+// Reading has a numeric discriminator at the edges of the 64-bit range.
+type Reading interface {
+	Sensor() string
+}
+
+type Thermometer struct {
+	Celsius int `json:"celsius"`
+}
+
+func (Thermometer) Sensor() string {
+	return "thermometer"
+}
+
+type Barometer struct {
+	Pascal int `json:"pascal"`
+}
+
+func (Barometer) Sensor() string {
+	return "barometer"
+}
+
+// The IDs differ by one, they would collapse into the same float64.
+const (
+	thermometerID = math.MaxInt64
+	barometerID   = math.MaxInt64 - 1
+)
+
+type Int64ReadingHelper struct {
+	ID int64 `json:"id"`
+}
+
+func (h *Int64ReadingHelper) Get() Reading {
+	switch h.ID {
+	case thermometerID:
+		return Thermometer{}
+	case barometerID:
+		return Barometer{}
+	}
+	return nil
+}
+
+func (h *Int64ReadingHelper) Set(r Reading) {
+	switch r.(type) {
+	case Thermometer:
+		h.ID = thermometerID
+	case Barometer:
+		h.ID = barometerID
+	}
+}
+
+type Uint64ReadingHelper struct {
+	ID uint64 `json:"id"`
+}
+
+func (h *Uint64ReadingHelper) Get() Reading {
+	switch h.ID {
+	case math.MaxUint64:
+		return Thermometer{}
+	case math.MaxUint64 - 1:
+		return Barometer{}
+	}
+	return nil
+}
+
+func (h *Uint64ReadingHelper) Set(r Reading) {
+	switch r.(type) {
+	case Thermometer:
+		h.ID = math.MaxUint64
+	case Barometer:
+		h.ID = math.MaxUint64 - 1
+	}
+}
+
+func TestContainer_int64Discriminator(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Reading
+		want string
+	}{{
+		name: "max_int64",
+		have: Thermometer{Celsius: 21},
+		want: `{"id":9223372036854775807,"celsius":21}`,
+	}, {
+		name: "max_int64_minus_one",
+		have: Barometer{Pascal: 101325},
+		want: `{"id":9223372036854775806,"pascal":101325}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Reading, *Int64ReadingHelper](t, tc.have, tc.want)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		var c Container[Reading, *Int64ReadingHelper]
+		err := json.Unmarshal([]byte(`{"id":-9223372036854775808}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+		// The discriminator in the error is exact as well.
+		if !strings.Contains(err.Error(), `{"id":-9223372036854775808}`) {
+			t.Fatalf("want exact discriminator in error, got %v", err)
+		}
+	})
+}
+
+func TestContainer_uint64Discriminator(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Reading
+		want string
+	}{{
+		name: "max_uint64",
+		have: Thermometer{Celsius: 21},
+		want: `{"id":18446744073709551615,"celsius":21}`,
+	}, {
+		name: "max_uint64_minus_one",
+		have: Barometer{Pascal: 101325},
+		want: `{"id":18446744073709551614,"pascal":101325}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Reading, *Uint64ReadingHelper](t, tc.have, tc.want)
+		})
+	}
+}
+
+// RawEventHelper stores events as raw JSON objects, the type is fixed.
+type RawEventHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *RawEventHelper) Get() json.RawMessage {
+	if h.Type == "event" {
+		return json.RawMessage{}
+	}
+	return nil
+}
+
+func (h *RawEventHelper) Set(json.RawMessage) {
+	h.Type = "event"
+}
+
+func TestContainer_rawMessageValue(t *testing.T) {
+	testCases := []struct {
+		name string
+		have json.RawMessage
+		want string
+	}{{
+		name: "object",
+		have: json.RawMessage(`{"id":1,"tags":["a","b"]}`),
+		want: `{"type":"event","id":1,"tags":["a","b"]}`,
+	}, {
+		name: "whitespace",
+		have: json.RawMessage(" \n{ \"id\" : 1 }\n"),
+		want: `{"type":"event","id":1}`,
+	}, {
+		name: "empty_object",
+		have: json.RawMessage(`{}`),
+		want: `{"type":"event"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(Container[json.RawMessage, *RawEventHelper]{Value: tc.have})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+
+			// The value receives the whole object, including the
+			// discriminator.
+			var c Container[json.RawMessage, *RawEventHelper]
+			if err := json.Unmarshal(got, &c); err != nil {
+				t.Fatal(err)
+			}
+			if string(c.Value) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, c.Value)
+			}
+		})
+	}
+
+	t.Run("not_object", func(t *testing.T) {
+		_, err := json.Marshal(Container[json.RawMessage, *RawEventHelper]{Value: json.RawMessage(`[1]`)})
+		if !errors.Is(err, ErrNotJSONObject) {
+			t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+		}
+	})
+}
+
+// EmptyHelper has no fields, so it can't produce a discriminator.
+type EmptyHelper struct{}
+
+func (h *EmptyHelper) Get() Animal {
+	return Dog{}
+}
+
+func (h *EmptyHelper) Set(Animal) {}
+
+// OmitEmptyHelper omits the discriminator if it's empty.
+type OmitEmptyHelper struct {
+	Type string `json:"type,omitempty"`
+}
+
+func (h *OmitEmptyHelper) Get() Animal {
+	return KnownAnimals[h.Type]
+}
+
+func (h *OmitEmptyHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestContainer_emptyHelper(t *testing.T) {
+	t.Run("no_fields", func(t *testing.T) {
+		_, err := json.Marshal(New[Animal, *EmptyHelper](Dog{XName: "Fido"}))
+		if !errors.Is(err, ErrMissingType) {
+			t.Fatalf("want %v, got %v", ErrMissingType, err)
+		}
+		if !strings.Contains(err.Error(), "*jsonpoly.EmptyHelper produced no fields") {
+			t.Fatalf("want descriptive error, got %v", err)
+		}
+	})
+
+	t.Run("omitted_fields", func(t *testing.T) {
+		_, err := json.Marshal(New[Animal, *OmitEmptyHelper](UnknownAnimal{XName: "Cooper"}))
+		if !errors.Is(err, ErrMissingType) {
+			t.Fatalf("want %v, got %v", ErrMissingType, err)
+		}
+
+		want := `{"type":"dog","name":"Fido","breed":""}`
+		testRoundTrip[Animal, *OmitEmptyHelper](t, Dog{XName: "Fido"}, want)
+	})
+
+	t.Run("untyped", func(t *testing.T) {
+		want := `{"name":"Fido","breed":""}`
+		testRoundTrip[Animal, *FixedDogHelper](t, Dog{XName: "Fido"}, want)
+	})
+}
@@ -0,0 +1,43 @@
+package jsonpoly
+
+// ContextHelper is an optional interface a Helper can implement if the type
+// of the value depends on data that is not part of the JSON object, e.g. on
+// the type of a parent object in nested documents. SetContext is called on a
+// newly allocated helper before the input is unmarshalled, with the context
+// passed to Container.UnmarshalWithContext. The context is not passed when
+// the container is unmarshalled using UnmarshalJSON.
+type ContextHelper interface {
+	SetContext(ctx any)
+}
+
+// UnmarshalWithContext works the same as UnmarshalJSON, but passes ctx to the
+// helper before the input is unmarshalled, if the helper implements
+// ContextHelper. This is typically called from the UnmarshalJSON method of a
+// parent type that determines the context:
+//
+//	func (e *Enclosure) UnmarshalJSON(b []byte) error {
+//		var raw struct {
+//			Habitat string          `json:"habitat"`
+//			Animal  json.RawMessage `json:"animal"`
+//		}
+//		if err := json.Unmarshal(b, &raw); err != nil {
+//			return err
+//		}
+//		e.Habitat = raw.Habitat
+//		return e.Animal.UnmarshalWithContext(raw.Habitat, raw.Animal)
+//	}
+func (c *Container[V, H]) UnmarshalWithContext(ctx any, b []byte) error {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return err
+	}
+	if h, ok := any(helper).(ContextHelper); ok {
+		h.SetContext(ctx)
+	}
+
+	res, err := decode[V](b, helper, false)
+	if res.valueDone {
+		c.Value, c.ID, c.raw = res.value, res.id, res.raw
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Lion is a cat living in the wild.
+type Lion struct {
+	XName string `json:"name"`
+	Pride string `json:"pride"`
+}
+
+func (Lion) Type() string {
+	return "cat"
+}
+func (l Lion) Name() string {
+	return l.XName
+}
+
+// HabitatAnimalHelper returns a Lion for cats in the wild and a Cat
+// otherwise.
+type HabitatAnimalHelper struct {
+	AnimalContainerHelper
+	habitat string
+}
+
+func (h *HabitatAnimalHelper) SetContext(ctx any) {
+	h.habitat, _ = ctx.(string)
+}
+
+func (h *HabitatAnimalHelper) Get() Animal {
+	if h.Type == "cat" && h.habitat == "wild" {
+		return Lion{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+// Enclosure determines the context of the animal it contains.
+type Enclosure struct {
+	Habitat string                                  `json:"habitat"`
+	Animal  Container[Animal, *HabitatAnimalHelper] `json:"animal"`
+}
+
+func (e *Enclosure) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Habitat string          `json:"habitat"`
+		Animal  json.RawMessage `json:"animal"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	e.Habitat = raw.Habitat
+	return e.Animal.UnmarshalWithContext(raw.Habitat, raw.Animal)
+}
+
+func TestContainer_UnmarshalWithContext(t *testing.T) {
+	const have = `{"type":"cat","name":"Simba","pride":"Pride Rock"}`
+
+	testCases := []struct {
+		name string
+		ctx  any
+		want Animal
+	}{{
+		name: "wild",
+		ctx:  "wild",
+		want: Lion{XName: "Simba", Pride: "Pride Rock"},
+	}, {
+		name: "home",
+		ctx:  "home",
+		want: Cat{XName: "Simba"},
+	}, {
+		name: "nil",
+		ctx:  nil,
+		want: Cat{XName: "Simba"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[Animal, *HabitatAnimalHelper]
+			if err := c.UnmarshalWithContext(tc.ctx, []byte(have)); err != nil {
+				t.Fatal(err)
+			}
+			if c.Value != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, c.Value)
+			}
+		})
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		var e []Enclosure
+		err := json.Unmarshal([]byte(`[
+			{"habitat":"wild","animal":`+have+`},
+			{"habitat":"home","animal":`+have+`}
+		]`), &e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := e[0].Animal.Value.(Lion); !ok {
+			t.Fatalf("want Lion, got %T", e[0].Animal.Value)
+		}
+		if _, ok := e[1].Animal.Value.(Cat); !ok {
+			t.Fatalf("want Cat, got %T", e[1].Animal.Value)
+		}
+	})
+
+	t.Run("without_context_helper", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalWithContext("wild", []byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := c.Value.(Cat); !ok {
+			t.Fatalf("want Cat, got %T", c.Value)
+		}
+	})
+}
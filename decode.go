@@ -0,0 +1,283 @@
+package jsonpoly
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// Unmarshal decodes a single polymorphic JSON object and returns the value. If
+// the value fails validation, the populated value is returned together with a
+// *ValidationError, on any other error the zero value is returned.
+func Unmarshal[V any, H Helper[V]](b []byte) (V, error) {
+	var c Container[V, H]
+	err := c.UnmarshalJSON(b)
+	return c.Value, err
+}
+
+// DecodeAs decodes a single polymorphic JSON object and returns the value as
+// the concrete type T, which is useful when the type is known at the call
+// site. Same as As, values and pointers are converted, so T can be *Dog even
+// if the helper returns Dog. If the object holds a different type, the
+// returned error wraps ErrTypeMismatch. Same as Unmarshal, the populated value
+// is returned on a validation failure.
+func DecodeAs[T any, V any, H Helper[V]](b []byte) (T, error) {
+	var zero T
+	v, err := Unmarshal[V, H](b)
+	var verr *ValidationError
+	if err != nil && !errors.As(err, &verr) {
+		return zero, err
+	}
+
+	t, ok := As[T](Container[V, H]{Value: v})
+	if !ok {
+		return zero, fmt.Errorf("%w: want %v, got %T", ErrTypeMismatch, reflect.TypeFor[T](), v)
+	}
+	return t, err
+}
+
+// DecodeSeparate decodes a value whose discriminator is transported
+// separately from the JSON body, e.g. in a message header. The type is not
+// read from the body, the discriminator key of the helper is set to typ
+// instead. If the body contains the key (in any casing), it is removed
+// before decoding. The helper needs to have
+// exactly one key. Same as Unmarshal, the populated value is returned on a
+// validation failure.
+func DecodeSeparate[V any, H Helper[V]](typ string, body []byte) (V, error) {
+	var zero V
+	key, err := discriminatorKey[V, H]()
+	if err != nil {
+		return zero, err
+	}
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return zero, err
+	}
+
+	t, err := json.Marshal(typ)
+	if err != nil {
+		return zero, err
+	}
+	o, err := writeObject([]objectField{{key: key, value: t}})
+	if err != nil {
+		return zero, err
+	}
+	opts := optionsOf(helper)
+	body = bytes.TrimSpace(body)
+	envelope := opts.Envelope
+	if !envelope && opts.AutoEnvelope {
+		if envelope, err = needsEnvelope(body, dataKey(helper)); err != nil {
+			return zero, err
+		}
+	}
+	if envelope {
+		o, err = writeObject([]objectField{{key: key, value: t}, {key: dataKey(helper), value: body}})
+	} else if body, err = omitFields(body, []string{key}); err == nil {
+		o, err = mergeJSONObjects(o, body)
+	}
+	if err != nil {
+		return zero, err
+	}
+	return Unmarshal[V, H](o)
+}
+
+// Decode decodes a single polymorphic JSON object using a preconstructed
+// helper, which avoids allocating a new helper for every call. The helper
+// needs to be a pointer, it is reset to its zero value (and initialized, if it
+// implements HelperInitializer) before it is used. Since the helper is
+// modified, Decode must not be called concurrently with the same helper, use
+// a separate helper per goroutine instead. Same as Unmarshal, the populated
+// value is returned on a validation failure.
+func Decode[V any](h Helper[V], b []byte) (V, error) {
+	var zero V
+	hv := reflect.ValueOf(h)
+	if hv.Kind() != reflect.Ptr || hv.IsNil() {
+		return zero, ErrHelperNotPointer
+	}
+	hv.Elem().SetZero()
+	if initializer, ok := h.(HelperInitializer); ok {
+		initializer.Init()
+	}
+
+	res, err := decode[V](b, h, false)
+	if !res.valueDone {
+		return zero, err
+	}
+	return res.value, err
+}
+
+// DecodeFrom decodes a single polymorphic JSON object from r. The object is
+// read using a json.Decoder, which only buffers the bytes of the object, as
+// opposed to reading the whole input into memory first. If r contains
+// anything but whitespace after the object, ErrTrailingData is returned. If
+// reading from r fails after the object, the wrapped read error is returned
+// instead. A leading UTF-8 byte order mark is skipped.
+func DecodeFrom[V any, H Helper[V]](r io.Reader) (V, error) {
+	var zero V
+	var c Container[V, H]
+
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+	dec := json.NewDecoder(br)
+	if err := dec.Decode(&c); err != nil {
+		return zero, err
+	}
+	// Reading the next token succeeds only if there is more data after the
+	// object, we expect the input to end. Syntax errors also mean there is
+	// more data, other errors come from the reader.
+	_, err := dec.Token()
+	var syntaxErr *json.SyntaxError
+	switch {
+	case err == io.EOF:
+		return c.Value, nil
+	case err == nil, errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return zero, ErrTrailingData
+	default:
+		return zero, fmt.Errorf("reading after JSON object: %w", err)
+	}
+}
+
+// DecodeLimited works the same as DecodeFrom, but reads at most maxBytes from
+// r. If the input is larger, ErrLimitExceeded is returned. This prevents
+// exhausting memory when decoding untrusted input. A limit of zero or less
+// allows no input at all, so ErrLimitExceeded is returned without reading
+// from r.
+func DecodeLimited[V any, H Helper[V]](r io.Reader, maxBytes int64) (V, error) {
+	var zero V
+	if maxBytes <= 0 {
+		return zero, ErrLimitExceeded
+	}
+	// Allow reading one byte more than the limit, so we can detect if the
+	// input exceeds it. The largest limit is used as it is, so it does not
+	// overflow.
+	n := maxBytes
+	if n < math.MaxInt64 {
+		n++
+	}
+	lr := &io.LimitedReader{R: r, N: n}
+	v, err := DecodeFrom[V, H](lr)
+	if lr.N <= 0 {
+		return zero, ErrLimitExceeded
+	}
+	return v, err
+}
+
+// DecodeSlice decodes a JSON array of polymorphic objects and appends the
+// values to *dst, which allows reusing a preallocated slice. If the input is
+// null, *dst is left untouched. If the input is an empty array and *dst is
+// nil, *dst is set to an empty non-nil slice.
+func DecodeSlice[V any, H Helper[V]](b []byte, dst *[]V) error {
+	var cs []Container[V, H]
+	if err := json.Unmarshal(b, &cs); err != nil {
+		return err
+	}
+	if cs == nil {
+		// The input was null.
+		return nil
+	}
+
+	if *dst == nil {
+		*dst = make([]V, 0, len(cs))
+	}
+	for _, c := range cs {
+		*dst = append(*dst, c.Value)
+	}
+	return nil
+}
+
+// Decoder reads a stream of polymorphic JSON objects. The objects can be
+// separated by whitespace (e.g. newline-delimited JSON) or directly adjacent
+// to each other (e.g. {...}{...}).
+type Decoder[V any, H Helper[V]] struct {
+	dec *json.Decoder
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder[V any, H Helper[V]](r io.Reader) *Decoder[V, H] {
+	return &Decoder[V, H]{dec: json.NewDecoder(r)}
+}
+
+// Decode reads the next object from the stream and returns its value. At the
+// end of the stream it returns io.EOF. Any other error is returned as a
+// *DecodeError containing the offset of the object in the stream.
+func (d *Decoder[V, H]) Decode() (V, error) {
+	var c Container[V, H]
+	// More skips the whitespace before the next object, so the offset points
+	// to the start of the object.
+	d.dec.More()
+	offset := d.dec.InputOffset()
+	if err := d.dec.Decode(&c); err != nil {
+		if err == io.EOF {
+			return c.Value, err
+		}
+		return c.Value, &DecodeError{Offset: offset, Err: err}
+	}
+	return c.Value, nil
+}
+
+// DecodeError is returned by Decoder when an object in the stream can not be
+// decoded.
+type DecodeError struct {
+	// Offset is the offset in bytes of the start of the object in the
+	// stream.
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("object at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Iterate decodes a stream of polymorphic JSON objects in a separate goroutine
+// and sends the values on the returned value channel. The channel is
+// unbuffered, so the stream is only read as fast as the values are consumed.
+// Both channels are closed at the end of the stream. If an object can not be
+// decoded, the error is sent on the error channel and decoding stops. To stop
+// consuming values early, cancel ctx, the goroutine then sends ctx.Err() on
+// the error channel and exits without reading the rest of the stream. Note
+// that a read from r that blocks can not be interrupted.
+func Iterate[V any, H Helper[V]](ctx context.Context, r io.Reader) (<-chan V, <-chan error) {
+	values := make(chan V)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(values)
+		defer close(errs)
+
+		dec := NewDecoder[V, H](r)
+		for {
+			if err := ctx.Err(); err != nil {
+				errs <- err
+				return
+			}
+			v, err := dec.Decode()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case values <- v:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return values, errs
+}
@@ -0,0 +1,541 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeAs(t *testing.T) {
+	const have = `{"type":"dog","name":"Fido","breed":"Pug"}`
+	want := Dog{XName: "Fido", Breed: "Pug"}
+
+	t.Run("value", func(t *testing.T) {
+		got, err := DecodeAs[Dog, Animal, *AnimalContainerHelper]([]byte(have))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		got, err := DecodeAs[*Dog, Animal, *AnimalContainerHelper]([]byte(have))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != want {
+			t.Fatalf("want %v, got %v", want, *got)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		got, err := DecodeAs[Cat, Animal, *AnimalContainerHelper]([]byte(have))
+		if !errors.Is(err, ErrTypeMismatch) {
+			t.Fatalf("want %v, got %v", ErrTypeMismatch, err)
+		}
+		if got != (Cat{}) {
+			t.Fatalf("want zero value, got %v", got)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := DecodeAs[Dog, Animal, *AnimalContainerHelper]([]byte(`{"type":"dog","name":1}`))
+		if err == nil || errors.Is(err, ErrTypeMismatch) {
+			t.Fatalf("want unmarshal error, got %v", err)
+		}
+	})
+
+	t.Run("validation_error", func(t *testing.T) {
+		got, err := DecodeAs[Hamster, Animal, *ValidatedAnimalHelper]([]byte(`{"type":"hamster","name":"Hammy","age":-1}`))
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("want validation error, got %v", err)
+		}
+		if want := (Hamster{XName: "Hammy", Age: -1}); got != want {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	})
+}
+
+func TestDecodeSeparate(t *testing.T) {
+	testCases := []struct {
+		name string
+		typ  string
+		body string
+		want Animal
+	}{{
+		name: "dog",
+		typ:  "dog",
+		body: `{"name":"Fido","breed":"Pug"}`,
+		want: Dog{XName: "Fido", Breed: "Pug"},
+	}, {
+		name: "cat",
+		typ:  "cat",
+		body: ` {"name":"Whiskers"} `,
+		want: Cat{XName: "Whiskers"},
+	}, {
+		name: "type_in_body",
+		typ:  "dog",
+		body: `{"type":"cat","name":"Fido"}`,
+		want: Dog{XName: "Fido"},
+	}, {
+		name: "type_in_body_other_case",
+		typ:  "dog",
+		body: `{"TYPE":"cat","name":"Fido","Type":"cat"}`,
+		want: Dog{XName: "Fido"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeSeparate[Animal, *AnimalContainerHelper](tc.typ, []byte(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("envelope", func(t *testing.T) {
+		want := Dog{XName: "Fido"}
+		got, err := DecodeSeparate[Animal, *EnvelopeAnimalHelper]("dog", []byte(`{"name":"Fido"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	})
+
+	t.Run("not_object", func(t *testing.T) {
+		_, err := DecodeSeparate[Animal, *AnimalContainerHelper]("dog", []byte(`["Fido"]`))
+		if !errors.Is(err, ErrNotJSONObject) {
+			t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+		}
+	})
+
+	t.Run("multiple_keys", func(t *testing.T) {
+		_, err := DecodeSeparate[Animal, *VersionedAnimalHelper]("dog", []byte(`{"name":"Fido"}`))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestDecodeFrom(t *testing.T) {
+	r := strings.NewReader(`{"type":"dog","name":"Fido","breed":"Golden Retriever"}`)
+
+	got, err := DecodeFrom[Animal, *AnimalContainerHelper](r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Dog{XName: "Fido", Breed: "Golden Retriever"}
+	if got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestDecodeFrom_bom(t *testing.T) {
+	r := strings.NewReader("\xEF\xBB\xBF" + `{"type":"dog","name":"Fido"}`)
+
+	got, err := DecodeFrom[Animal, *AnimalContainerHelper](r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Dog{XName: "Fido"}
+	if got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestDecodeFrom_error(t *testing.T) {
+	r := strings.NewReader(`{"type":"dog","name":1}`)
+
+	got, err := DecodeFrom[Animal, *AnimalContainerHelper](r)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got != nil {
+		t.Fatalf("want nil, got %v", got)
+	}
+}
+
+func TestDecodeFrom_trailingData(t *testing.T) {
+	for _, raw := range []string{
+		`{"type":"dog","name":"Fido"} extra`,
+		`{"type":"dog","name":"Fido"}{"type":"cat"}`,
+		`{"type":"dog","name":"Fido"}]`,
+		`{"type":"dog","name":"Fido"} "unterminated`,
+	} {
+		_, err := DecodeFrom[Animal, *AnimalContainerHelper](strings.NewReader(raw))
+		if !errors.Is(err, ErrTrailingData) {
+			t.Fatalf("%s: want %v, got %v", raw, ErrTrailingData, err)
+		}
+	}
+
+	// Trailing whitespace is allowed.
+	_, err := DecodeFrom[Animal, *AnimalContainerHelper](strings.NewReader(`{"type":"dog"}` + "\n "))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeFrom_readError(t *testing.T) {
+	errReset := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader(`{"type":"dog","name":"Fido"}`), iotest.ErrReader(errReset))
+
+	_, err := DecodeFrom[Animal, *AnimalContainerHelper](r)
+	if !errors.Is(err, errReset) {
+		t.Fatalf("want %v, got %v", errReset, err)
+	}
+	if errors.Is(err, ErrTrailingData) {
+		t.Fatalf("want read error, got %v", err)
+	}
+}
+
+func TestDecodeLimited(t *testing.T) {
+	raw := `{"type":"dog","name":"Fido","breed":"Golden Retriever"}`
+
+	got, err := DecodeLimited[Animal, *AnimalContainerHelper](strings.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Dog{XName: "Fido", Breed: "Golden Retriever"}
+	if got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	for _, limit := range []int64{math.MinInt64, -1, 0, 10, int64(len(raw)) - 1} {
+		_, err := DecodeLimited[Animal, *AnimalContainerHelper](strings.NewReader(raw), limit)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("limit %d: want %v, got %v", limit, ErrLimitExceeded, err)
+		}
+	}
+
+	// The largest limit does not overflow.
+	got, err = DecodeLimited[Animal, *AnimalContainerHelper](strings.NewReader(raw), math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// Trailing whitespace counts towards the limit.
+	_, err = DecodeLimited[Animal, *AnimalContainerHelper](strings.NewReader(raw+"   "), int64(len(raw)))
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("want %v, got %v", ErrLimitExceeded, err)
+	}
+}
+
+func TestDecodeSlice(t *testing.T) {
+	raw := `[{"type":"dog","name":"Fido","breed":"Golden Retriever"},{"type":"cat","name":"Whiskers"}]`
+
+	dst := make([]Animal, 1, 3)
+	dst[0] = Bird{XType: "bird", XName: "Tweety"}
+
+	if err := DecodeSlice[Animal, *AnimalContainerHelper]([]byte(raw), &dst); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Animal{
+		Bird{XType: "bird", XName: "Tweety"},
+		Dog{XName: "Fido", Breed: "Golden Retriever"},
+		Cat{XName: "Whiskers"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("want %v, got %v", want, dst)
+	}
+}
+
+func TestDecodeSlice_nullAndEmpty(t *testing.T) {
+	var dst []Animal
+	if err := DecodeSlice[Animal, *AnimalContainerHelper]([]byte(`null`), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst != nil {
+		t.Fatalf("want nil, got %v", dst)
+	}
+
+	if err := DecodeSlice[Animal, *AnimalContainerHelper]([]byte(`[]`), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst == nil || len(dst) != 0 {
+		t.Fatalf("want empty slice, got %#v", dst)
+	}
+}
+
+func TestDecodeSlice_error(t *testing.T) {
+	var dst []Animal
+	err := DecodeSlice[Animal, *AnimalContainerHelper]([]byte(`[{"type":"dog"},"cat"]`), &dst)
+	if !errors.Is(err, ErrNotJSONObject) {
+		t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+	}
+}
+
+var benchmarkInput = []byte(`{"type":"cat","name":"Whiskers","owner":"` + strings.Repeat("Alice", 1000) + `","color":"White"}`)
+
+func TestDecoder(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+	}{{
+		name: "newline_separated",
+		have: "{\"type\":\"dog\",\"name\":\"Fido\"}\n{\"type\":\"cat\",\"name\":\"Tom\"}\n",
+	}, {
+		name: "whitespace_separated",
+		have: `{"type":"dog","name":"Fido"}   {"type":"cat","name":"Tom"}`,
+	}, {
+		name: "adjacent",
+		have: `{"type":"dog","name":"Fido"}{"type":"cat","name":"Tom"}`,
+	}}
+
+	want := []Animal{Dog{XName: "Fido"}, Cat{XName: "Tom"}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewDecoder[Animal, *AnimalContainerHelper](strings.NewReader(tc.have))
+
+			var got []Animal
+			for {
+				v, err := dec.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("want %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestDecoder_error(t *testing.T) {
+	dec := NewDecoder[Animal, *AnimalContainerHelper](strings.NewReader(`{"type":"dog"}[]`))
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); !errors.Is(err, ErrNotJSONObject) {
+		t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+	}
+}
+
+func TestDecoder_errorOffset(t *testing.T) {
+	testCases := []struct {
+		name       string
+		have       string
+		wantOffset int64
+		wantErr    error
+	}{{
+		name:       "invalid_value",
+		have:       "{\"type\":\"dog\",\"name\":\"Fido\"}\n{\"type\":\"cat\",\"name\":\"Tom\"}\n  {\"type\":\"dog\",\"name\":1}\n",
+		wantOffset: 59,
+	}, {
+		name:       "unknown_type",
+		have:       `{"type":"dog"}{"type":"cat"}{"type":"dolphin"}`,
+		wantOffset: 28,
+		wantErr:    ErrUnknownType,
+	}, {
+		name:       "first_object",
+		have:       `  {"type":"dolphin"}`,
+		wantOffset: 2,
+		wantErr:    ErrUnknownType,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewDecoder[Animal, *AnimalRegistryHelper](strings.NewReader(tc.have))
+
+			var err error
+			for err == nil {
+				_, err = dec.Decode()
+			}
+
+			var decErr *DecodeError
+			if !errors.As(err, &decErr) {
+				t.Fatalf("want DecodeError, got %v", err)
+			}
+			if decErr.Offset != tc.wantOffset {
+				t.Fatalf("want offset %d, got %d", tc.wantOffset, decErr.Offset)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v, got %v", tc.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), fmt.Sprintf("offset %d", tc.wantOffset)) {
+				t.Fatalf("expected offset in error message, got %q", err)
+			}
+		})
+	}
+}
+
+func TestIterate(t *testing.T) {
+	r := strings.NewReader(`{"type":"dog","name":"Fido"}
+{"type":"cat","name":"Tom"}{"type":"dog","name":"Rex"}`)
+
+	values, errs := Iterate[Animal, *AnimalContainerHelper](context.Background(), r)
+
+	var got []Animal
+	for v := range values {
+		got = append(got, v)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Animal{Dog{XName: "Fido"}, Cat{XName: "Tom"}, Dog{XName: "Rex"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestIterate_error(t *testing.T) {
+	r := strings.NewReader(`{"type":"dog","name":"Fido"}{"type":"dolphin"}{"type":"cat"}`)
+
+	values, errs := Iterate[Animal, *AnimalRegistryHelper](context.Background(), r)
+
+	var got []Animal
+	for v := range values {
+		got = append(got, v)
+	}
+	if err := <-errs; !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("want %v, got %v", ErrUnknownType, err)
+	}
+	// The error channel is closed after the error.
+	if err, ok := <-errs; ok {
+		t.Fatalf("expected closed error channel, got %v", err)
+	}
+
+	want := []Animal{Dog{XName: "Fido"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestIterate_cancel(t *testing.T) {
+	r := strings.NewReader(strings.Repeat(`{"type":"dog","name":"Fido"}`, 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	values, errs := Iterate[Animal, *AnimalContainerHelper](ctx, r)
+
+	if v := <-values; v != (Dog{XName: "Fido"}) {
+		t.Fatalf("want %v, got %v", Dog{XName: "Fido"}, v)
+	}
+	// The consumer stops reading, the goroutine exits after the cancellation
+	// and closes both channels.
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want %v, got %v", context.Canceled, err)
+	}
+	for range values {
+	}
+}
+
+func TestDecode(t *testing.T) {
+	h := &AnimalRegistryHelper{}
+
+	got, err := Decode[Animal](h, []byte(`{"type":"dog","name":"Fido"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Dog{XName: "Fido"}); got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// The helper is reset, the type from the previous call is not reused.
+	_, err = Decode[Animal](h, []byte(`{"name":"Tom"}`))
+	if !errors.Is(err, ErrMissingType) {
+		t.Fatalf("want %v, got %v", ErrMissingType, err)
+	}
+
+	got, err = Decode[Animal](h, []byte(`{"type":"cat","name":"Tom"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Cat{XName: "Tom"}); got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestDecode_initializer(t *testing.T) {
+	h := &RegistryRefHelper{}
+	got, err := Decode[Animal](h, []byte(`{"type":"dog","name":"Fido"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Dog{XName: "Fido"}); got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestDecode_notPointer(t *testing.T) {
+	_, err := Decode[Animal](AnimalValueHelper{}, []byte(`{"type":"dog"}`))
+	if !errors.Is(err, ErrHelperNotPointer) {
+		t.Fatalf("want %v, got %v", ErrHelperNotPointer, err)
+	}
+	_, err = Decode[Animal]((*AnimalContainerHelper)(nil), []byte(`{"type":"dog"}`))
+	if !errors.Is(err, ErrHelperNotPointer) {
+		t.Fatalf("want %v, got %v", ErrHelperNotPointer, err)
+	}
+}
+
+func BenchmarkDecodeFrom(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := DecodeFrom[Animal, *AnimalContainerHelper](bytes.NewReader(benchmarkInput))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeFrom_readAll(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		raw, err := io.ReadAll(bytes.NewReader(benchmarkInput))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := json.Unmarshal(raw, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	raw := []byte(`{"type":"cat","name":"Whiskers","owner":"Alice","color":"White"}`)
+
+	b.Run("shared_helper", func(b *testing.B) {
+		h := &AnimalContainerHelper{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Decode[Animal](h, raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("new_helper", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Unmarshal[Animal, *AnimalContainerHelper](raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
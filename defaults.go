@@ -0,0 +1,30 @@
+package jsonpoly
+
+// Defaulter is an optional interface a value or a helper can implement to
+// provide default field values. When unmarshalling, Defaults is called on the
+// value returned by Helper.Get (or on the helper, if the value does not
+// implement Defaulter) and the JSON is unmarshalled on top of the returned
+// value, so fields missing in the input keep their defaults, while fields
+// present in the input override them.
+//
+// Defaults should return a new value each time it is called. If it returns a
+// pointer, the pointer must not be shared, otherwise unmarshalling would
+// modify the defaults.
+type Defaulter[V any] interface {
+	Defaults() V
+}
+
+// defaults returns the defaults for v if v or the helper implement Defaulter,
+// otherwise it returns v as is.
+func defaults[V any](v V, helper Helper[V]) V {
+	if isNil(v) {
+		return v
+	}
+	if d, ok := any(v).(Defaulter[V]); ok {
+		return d.Defaults()
+	}
+	if d, ok := helper.(Defaulter[V]); ok {
+		return d.Defaults()
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Kitten provides its own defaults.
+type Kitten struct {
+	XName string `json:"name"`
+	Color string `json:"color"`
+}
+
+func (Kitten) Type() string {
+	return "kitten"
+}
+func (k Kitten) Name() string {
+	return k.XName
+}
+func (Kitten) Defaults() Animal {
+	return Kitten{Color: "black"}
+}
+
+// Puppy provides its own defaults and is used as a pointer.
+type Puppy struct {
+	XName string `json:"name"`
+	Breed string `json:"breed"`
+}
+
+func (*Puppy) Type() string {
+	return "puppy"
+}
+func (p *Puppy) Name() string {
+	return p.XName
+}
+func (*Puppy) Defaults() Animal {
+	return &Puppy{Breed: "mixed"}
+}
+
+// sharedPuppy is returned by the helper, it must not be modified.
+var sharedPuppy = &Puppy{}
+
+// DefaultingAnimalHelper provides defaults for cats, kittens and puppies
+// provide their own defaults.
+type DefaultingAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *DefaultingAnimalHelper) Get() Animal {
+	switch h.Type {
+	case "kitten":
+		return Kitten{}
+	case "puppy":
+		return sharedPuppy
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func (h *DefaultingAnimalHelper) Defaults() Animal {
+	if h.Type == "cat" {
+		return Cat{Color: "tabby"}
+	}
+	return h.Get()
+}
+
+func TestDefaulter(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		want Animal
+	}{{
+		name: "value_default",
+		have: `{"type":"kitten","name":"Tom"}`,
+		want: Kitten{XName: "Tom", Color: "black"},
+	}, {
+		name: "value_override",
+		have: `{"type":"kitten","name":"Tom","color":"white"}`,
+		want: Kitten{XName: "Tom", Color: "white"},
+	}, {
+		name: "helper_default",
+		have: `{"type":"cat","name":"Garfield"}`,
+		want: Cat{XName: "Garfield", Color: "tabby"},
+	}, {
+		name: "helper_override",
+		have: `{"type":"cat","name":"Garfield","color":"orange"}`,
+		want: Cat{XName: "Garfield", Color: "orange"},
+	}, {
+		name: "helper_override_null",
+		have: `{"type":"cat","name":"Garfield","color":null}`,
+		want: Cat{XName: "Garfield", Color: "tabby"},
+	}, {
+		name: "without_defaults",
+		have: `{"type":"dog","name":"Fido"}`,
+		want: Dog{XName: "Fido"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[Animal, *DefaultingAnimalHelper]
+			if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+				t.Fatal(err)
+			}
+			if c.Value != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, c.Value)
+			}
+		})
+	}
+
+	t.Run("pointer", func(t *testing.T) {
+		var c Container[Animal, *DefaultingAnimalHelper]
+		if err := json.Unmarshal([]byte(`{"type":"puppy","name":"Rex"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		want := Puppy{XName: "Rex", Breed: "mixed"}
+		if got, ok := c.Value.(*Puppy); !ok || *got != want {
+			t.Fatalf("want %#v, got %#v", &want, c.Value)
+		}
+		if *sharedPuppy != (Puppy{}) {
+			t.Fatalf("value returned by Get was modified: %#v", sharedPuppy)
+		}
+	})
+}
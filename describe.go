@@ -0,0 +1,35 @@
+package jsonpoly
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Descriptor contains metadata about an instantiation of Container.
+type Descriptor struct {
+	// ValueType is the type of the contained value (i.e. V).
+	ValueType reflect.Type
+	// HelperType is the type of the helper (i.e. H).
+	HelperType reflect.Type
+	// Keys are the JSON keys read by the helper to determine the type of the
+	// value. Keys is nil if the keys are not statically known, e.g. if the
+	// helper implements custom unmarshalling.
+	Keys []string
+}
+
+// Describe returns the Descriptor of Container[V, H], without needing an
+// instance of the container. This is useful for building routing tables over
+// many container types.
+func Describe[V any, H Helper[V]]() Descriptor {
+	d := Descriptor{
+		ValueType:  reflect.TypeFor[V](),
+		HelperType: reflect.TypeFor[H](),
+	}
+	if d.HelperType.Kind() == reflect.Ptr {
+		if keys, ok := helperKeys(d.HelperType.Elem()); ok {
+			// Clone the keys, so the cached slice can't be modified.
+			d.Keys = slices.Clone(keys)
+		}
+	}
+	return d
+}
@@ -0,0 +1,62 @@
+package jsonpoly
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	testCases := []struct {
+		name string
+		got  Descriptor
+		want Descriptor
+	}{{
+		name: "struct_helper",
+		got:  Describe[Animal, *AnimalContainerHelper](),
+		want: Descriptor{
+			ValueType:  reflect.TypeFor[Animal](),
+			HelperType: reflect.TypeFor[*AnimalContainerHelper](),
+			Keys:       []string{"type"},
+		},
+	}, {
+		name: "embedded_helper",
+		got:  Describe[Animal, *VersionedAnimalHelper](),
+		want: Descriptor{
+			ValueType:  reflect.TypeFor[Animal](),
+			HelperType: reflect.TypeFor[*VersionedAnimalHelper](),
+			Keys:       []string{"type", "version"},
+		},
+	}, {
+		name: "map_value",
+		got:  Describe[map[string]any, *MapHelper](),
+		want: Descriptor{
+			ValueType:  reflect.TypeFor[map[string]any](),
+			HelperType: reflect.TypeFor[*MapHelper](),
+			Keys:       []string{"type"},
+		},
+	}, {
+		name: "non_pointer_helper",
+		got:  Describe[Animal, AnimalValueHelper](),
+		want: Descriptor{
+			ValueType:  reflect.TypeFor[Animal](),
+			HelperType: reflect.TypeFor[AnimalValueHelper](),
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tc.got, tc.want) {
+				t.Fatalf("want %v, got %v", tc.want, tc.got)
+			}
+		})
+	}
+}
+
+func TestDescribe_keysCopied(t *testing.T) {
+	d := Describe[Animal, *AnimalContainerHelper]()
+	d.Keys[0] = "modified"
+
+	if got := Describe[Animal, *AnimalContainerHelper]().Keys; got[0] != "type" {
+		t.Fatalf("expected cached keys to be unchanged, got %v", got)
+	}
+}
@@ -0,0 +1,90 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Dispatcher decodes polymorphic JSON objects and passes the values to the
+// handler registered for their discriminator, which makes it a routing
+// primitive for event consumers. The helper needs to marshal into an object
+// with a single string field containing the discriminator. The zero value
+// is ready to use.
+type Dispatcher[V any, H Helper[V]] struct {
+	handlers map[string]func(V) error
+
+	// Default is called for values with a discriminator that has no
+	// registered handler. If the helper does not recognize the type, v is the
+	// zero value. If Default is nil, Handle returns ErrUnknownType instead.
+	Default func(typ string, v V) error
+}
+
+// Register registers the handler for values with the discriminator typ. It
+// panics if a handler for typ is already registered.
+func (d *Dispatcher[V, H]) Register(typ string, fn func(V) error) {
+	if _, ok := d.handlers[typ]; ok {
+		panic(fmt.Sprintf("jsonpoly: handler for %q already registered", typ))
+	}
+	if d.handlers == nil {
+		d.handlers = make(map[string]func(V) error)
+	}
+	d.handlers[typ] = fn
+}
+
+// Handle decodes the JSON object and calls the handler registered for its
+// discriminator. The discriminator is determined by passing the decoded value
+// to Helper.Set, so the handler registered for the canonical discriminator is
+// called, even if the input uses e.g. an alias. The error returned by the
+// handler is returned as is.
+func (d *Dispatcher[V, H]) Handle(b []byte) error {
+	var c Container[V, H]
+	helper, err := c.UnmarshalWithHelper(b)
+	if err != nil && !errors.Is(err, ErrUnknownType) {
+		return err
+	}
+	if err == nil {
+		// Determine the discriminator from the value, so that e.g. aliases
+		// resolve to the canonical discriminator.
+		if helper, err = newHelper[V, H](); err != nil {
+			return err
+		}
+		helper.Set(c.Value)
+	}
+
+	typ, typErr := discriminatorValue(helper)
+	if typErr != nil {
+		return typErr
+	}
+	if err == nil {
+		if fn, ok := d.handlers[typ]; ok {
+			return fn(c.Value)
+		}
+	}
+	if d.Default == nil {
+		return fmt.Errorf("%w %q", ErrUnknownType, typ)
+	}
+	return d.Default(typ, c.Value)
+}
+
+// discriminatorValue returns the discriminator of a populated helper, which
+// needs to marshal into an object with a single string field.
+func discriminatorValue(helper any) (string, error) {
+	b, err := json.Marshal(helper)
+	if err != nil {
+		return "", err
+	}
+	fields, err := parseObject(b)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) != 1 {
+		return "", fmt.Errorf("helper %T needs to have exactly one key, got %d", helper, len(fields))
+	}
+
+	var typ string
+	if err := json.Unmarshal(fields[0].value, &typ); err != nil {
+		return "", fmt.Errorf("discriminator %q is not a string: %w", fields[0].key, err)
+	}
+	return typ, nil
+}
@@ -0,0 +1,122 @@
+package jsonpoly
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	var got []string
+
+	var d Dispatcher[Animal, *AnimalRegistryHelper]
+	d.Register("dog", func(a Animal) error {
+		got = append(got, "dog:"+a.Name())
+		return nil
+	})
+	d.Register("cat", func(a Animal) error {
+		got = append(got, "cat:"+a.Name())
+		return nil
+	})
+	d.Default = func(typ string, a Animal) error {
+		got = append(got, "default:"+typ)
+		if a != nil {
+			t.Fatalf("expected nil value, got %v", a)
+		}
+		return nil
+	}
+
+	for _, raw := range []string{
+		`{"type":"dog","name":"Fido"}`,
+		`{"type":"cat","name":"Tom"}`,
+		`{"type":"dolphin","name":"Cooper"}`,
+		`{"type":"k9","name":"Rex"}`,
+	} {
+		if err := d.Handle([]byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The alias k9 is dispatched to the handler of the canonical type.
+	want := []string{"dog:Fido", "cat:Tom", "default:dolphin", "dog:Rex"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestDispatcher_fallbackValue(t *testing.T) {
+	// AnimalContainerHelper returns UnknownAnimal for unknown types, which is
+	// passed to the default handler.
+	var got Animal
+	d := Dispatcher[Animal, *AnimalContainerHelper]{
+		Default: func(typ string, a Animal) error {
+			got = a
+			return nil
+		},
+	}
+	if err := d.Handle([]byte(`{"type":"dolphin","name":"Cooper"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := (UnknownAnimal{XType: "dolphin", XName: "Cooper"}); got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestDispatcher_error(t *testing.T) {
+	errHandler := errors.New("handler error")
+
+	var d Dispatcher[Animal, *AnimalRegistryHelper]
+	d.Register("dog", func(Animal) error { return errHandler })
+
+	testCases := []struct {
+		name    string
+		have    string
+		wantErr error
+	}{{
+		name:    "handler",
+		have:    `{"type":"dog"}`,
+		wantErr: errHandler,
+	}, {
+		name:    "no_default",
+		have:    `{"type":"cat"}`,
+		wantErr: ErrUnknownType,
+	}, {
+		name:    "unknown_no_default",
+		have:    `{"type":"dolphin"}`,
+		wantErr: ErrUnknownType,
+	}, {
+		name:    "missing_type",
+		have:    `{"name":"Fido"}`,
+		wantErr: ErrMissingType,
+	}, {
+		name:    "not_object",
+		have:    `[]`,
+		wantErr: ErrNotJSONObject,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := d.Handle([]byte(tc.have)); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("multiple_keys", func(t *testing.T) {
+		var d Dispatcher[Animal, *VersionedAnimalHelper]
+		if err := d.Handle([]byte(`{"type":"dog"}`)); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestDispatcher_RegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	var d Dispatcher[Animal, *AnimalRegistryHelper]
+	d.Register("dog", func(Animal) error { return nil })
+	d.Register("dog", func(Animal) error { return nil })
+}
@@ -0,0 +1,44 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteNDJSON writes the values to w as newline-delimited JSON, each value as
+// a polymorphic object on its own line. The output can be read back using a
+// Decoder. Nil values are written as null. If a value can not be marshalled,
+// the returned error contains the index of the value, the values before it
+// are already written to w.
+func WriteNDJSON[V any, H Helper[V]](w io.Writer, values []V) error {
+	enc := json.NewEncoder(w)
+	// The container escapes the output according to its options, the
+	// encoder should not escape it again.
+	enc.SetEscapeHTML(false)
+	for i, v := range values {
+		if err := enc.Encode(Container[V, H]{Value: v}); err != nil {
+			return fmt.Errorf("value at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MarshalType marshals only the fields of the helper for the value v (e.g.
+// {"type":"dog"}), without the fields of the value. This is useful for
+// building indexes or manifests that list types without payloads. Keys owned
+// by the value (see ValueOwner) are omitted and DiscriminatorMapper and
+// KeyOrderer are applied, the same as in MarshalJSON. The options of the
+// helper are not applied, so Options.IDKey, the escaping options and
+// PostMarshaler don't change the output, and a helper with Options.Untyped
+// still needs to produce its fields. A nil value is marshalled as null.
+func MarshalType[V any, H Helper[V]](v V) ([]byte, error) {
+	if isNil(v) {
+		return []byte("null"), nil
+	}
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return nil, err
+	}
+	return marshalHelper[V](helper, v)
+}
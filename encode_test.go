@@ -0,0 +1,139 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteNDJSON(t *testing.T) {
+	have := []Animal{
+		Dog{XName: "Fido", Breed: "Pug"},
+		Cat{XName: "Tom", Color: "grey"},
+		Bird{XType: "bird", XName: "Tweety"},
+	}
+	want := `{"type":"dog","name":"Fido","breed":"Pug"}
+{"type":"cat","name":"Tom","owner":"","color":"grey"}
+{"type":"bird","name":"Tweety"}
+`
+
+	var buf bytes.Buffer
+	if err := WriteNDJSON[Animal, *AnimalContainerHelper](&buf, have); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Fatalf("want %s, got %s", want, buf.String())
+	}
+
+	var got []Animal
+	dec := NewDecoder[Animal, *AnimalContainerHelper](&buf)
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, have) {
+		t.Fatalf("want %v, got %v", have, got)
+	}
+}
+
+// IndentingAnimalHelper produces output spanning multiple lines.
+type IndentingAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *IndentingAnimalHelper) PostMarshal(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.Indent(&buf, b, "", "  ")
+	return buf.Bytes(), err
+}
+
+func TestWriteNDJSON_multiline(t *testing.T) {
+	// The output of the container is compacted into a single line.
+	var buf bytes.Buffer
+	if err := WriteNDJSON[Animal, *IndentingAnimalHelper](&buf, []Animal{Dog{XName: "Fido"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"type\":\"dog\",\"name\":\"Fido\",\"breed\":\"\"}\n"; buf.String() != want {
+		t.Fatalf("want %q, got %q", want, buf.String())
+	}
+}
+
+func TestWriteNDJSON_error(t *testing.T) {
+	have := []Animal{
+		Dog{XName: "Fido"},
+		UnknownAnimal{XName: "Cooper"}, // No discriminator.
+		Dog{XName: "Rex"},
+	}
+
+	var buf bytes.Buffer
+	err := WriteNDJSON[Animal, *OmitEmptyHelper](&buf, have)
+	if !errors.Is(err, ErrMissingType) {
+		t.Fatalf("want %v, got %v", ErrMissingType, err)
+	}
+	if !strings.Contains(err.Error(), "value at index 1") {
+		t.Fatalf("want index in error, got %v", err)
+	}
+	// The values before the failing one are written.
+	if want := "{\"type\":\"dog\",\"name\":\"Fido\",\"breed\":\"\"}\n"; buf.String() != want {
+		t.Fatalf("want %q, got %q", want, buf.String())
+	}
+}
+
+func TestMarshalType(t *testing.T) {
+	t.Run("dog", func(t *testing.T) {
+		got, err := MarshalType[Animal, *AnimalContainerHelper](Dog{XName: "Fido", Breed: "Pug"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dog"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("multiple_keys", func(t *testing.T) {
+		got, err := MarshalType[map[string]any, *DimensionFirstHelper](map[string]any{"k": "cube", "d": 3, "e": "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"dimension":3,"kind":"cube","extra":"x"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("mapped", func(t *testing.T) {
+		got, err := MarshalType[Animal, *NamespacedAnimalHelper](Cat{XName: "Tom"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"animal/cat"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		got, err := MarshalType[Animal, *AnimalContainerHelper](nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `null`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("missing_type", func(t *testing.T) {
+		_, err := MarshalType[Animal, *OmitEmptyHelper](UnknownAnimal{XName: "Cooper"})
+		if !errors.Is(err, ErrMissingType) {
+			t.Fatalf("want %v, got %v", ErrMissingType, err)
+		}
+	})
+}
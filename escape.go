@@ -0,0 +1,70 @@
+package jsonpoly
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const hex = "0123456789abcdef"
+
+// escapeStrings rewrites the strings in the JSON document b. If unescapeHTML
+// is true, the escape sequences for <, > and & produced by encoding/json are
+// replaced with the characters. If escapeNonASCII is true, non-ASCII
+// characters are replaced with \uXXXX escape sequences. Anything outside of
+// strings is copied as is.
+func escapeStrings(b []byte, unescapeHTML, escapeNonASCII bool) []byte {
+	out := make([]byte, 0, len(b))
+	inString := false
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '"':
+			inString = !inString
+			out = append(out, c)
+			i++
+		case !inString:
+			out = append(out, c)
+			i++
+		case c == '\\':
+			if unescapeHTML && i+5 < len(b) && b[i+1] == 'u' {
+				if r, ok := htmlEscapes[string(b[i+2:i+6])]; ok {
+					out = append(out, r)
+					i += 6
+					continue
+				}
+			}
+			// Copy the escape sequence, so an escaped quote does not end
+			// the string.
+			end := min(i+2, len(b))
+			out = append(out, b[i:end]...)
+			i = end
+		case c >= utf8.RuneSelf && escapeNonASCII:
+			r, size := utf8.DecodeRune(b[i:])
+			if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+				out = appendUnicodeEscape(out, r1)
+				out = appendUnicodeEscape(out, r2)
+			} else {
+				out = appendUnicodeEscape(out, r)
+			}
+			i += size
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return out
+}
+
+// htmlEscapes maps the hex digits of the escape sequences produced by
+// encoding/json for HTML characters to the characters.
+var htmlEscapes = map[string]byte{
+	"003c": '<',
+	"003e": '>',
+	"0026": '&',
+}
+
+// appendUnicodeEscape appends the \uXXXX escape sequence of r to b, r needs to
+// be in the Basic Multilingual Plane.
+func appendUnicodeEscape(b []byte, r rune) []byte {
+	return append(b, '\\', 'u', hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+}
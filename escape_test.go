@@ -0,0 +1,134 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEscapeStrings(t *testing.T) {
+	testCases := []struct {
+		name           string
+		have           string
+		unescapeHTML   bool
+		escapeNonASCII bool
+		want           string
+	}{{
+		name:         "unescape_html",
+		have:         `{"a\u003cb":"\u003cp\u003e \u0026amp;"}`,
+		unescapeHTML: true,
+		want:         `{"a<b":"<p> &amp;"}`,
+	}, {
+		name:         "unescape_html_escaped_backslash",
+		have:         `{"a":"\\u003c \"\u003e\""}`,
+		unescapeHTML: true,
+		want:         `{"a":"\\u003c \">\""}`,
+	}, {
+		name:         "unescape_html_other_escapes",
+		have:         `{"a":"\u00e9\n "}`,
+		unescapeHTML: true,
+		want:         `{"a":"\u00e9\n "}`,
+	}, {
+		name:           "escape_non_ascii",
+		have:           `{"café":"über 🐶"}`,
+		escapeNonASCII: true,
+		want:           `{"caf\u00e9":"\u00fcber \ud83d\udc36"}`,
+	}, {
+		name:           "both",
+		have:           `{"a":"\u003cé\u003e"}`,
+		unescapeHTML:   true,
+		escapeNonASCII: true,
+		want:           `{"a":"<\u00e9>"}`,
+	}, {
+		name:           "outside_strings",
+		have:           ` { "a" : [1, true, null] } `,
+		unescapeHTML:   true,
+		escapeNonASCII: true,
+		want:           ` { "a" : [1, true, null] } `,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := escapeStrings([]byte(tc.have), tc.unescapeHTML, tc.escapeNonASCII)
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+type UnescapedHTMLAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *UnescapedHTMLAnimalHelper) Options() Options {
+	return Options{DisableHTMLEscape: true}
+}
+
+type ASCIIAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *ASCIIAnimalHelper) Options() Options {
+	return Options{EscapeNonASCII: true}
+}
+
+func TestOptions_escaping(t *testing.T) {
+	have := UnknownAnimal{XType: "chien<é>", XName: "Fido & Rex 🐶"}
+
+	t.Run("default", func(t *testing.T) {
+		got, err := New[Animal, *AnimalContainerHelper](have).MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"type":"chien\u003cé\u003e","name":"Fido \u0026 Rex 🐶"}`
+		if string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("disable_html_escape", func(t *testing.T) {
+		want := `{"type":"chien<é>","name":"Fido & Rex 🐶"}`
+
+		c := New[Animal, *UnescapedHTMLAnimalHelper](have)
+		got, err := c.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+
+		// json.Marshal would escape the output again, an encoder does not.
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(c); err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.TrimSpace(buf.Bytes()); string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("escape_non_ascii", func(t *testing.T) {
+		want := `{"type":"chien\u003c\u00e9\u003e","name":"Fido \u0026 Rex \ud83d\udc36"}`
+
+		got, err := json.Marshal(New[Animal, *ASCIIAnimalHelper](have))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+
+		// The escaped output decodes into the same value.
+		var c Container[Animal, *ASCIIAnimalHelper]
+		if err := json.Unmarshal(got, &c); err != nil {
+			t.Fatal(err)
+		}
+		if want := (UnknownAnimal{XType: "chien<é>", XName: "Fido & Rex 🐶"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package example
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DateLayout is the layout used to marshal and unmarshal Date.
+const DateLayout = "2006-01-02"
+
+// Date is a time.Time that is marshalled as a date without the time, e.g.
+// "2024-05-17". Using a custom type for fields with a non-RFC 3339 format
+// works for fields at any depth, without needing global state.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(DateLayout))
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
@@ -0,0 +1,32 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+func ExampleDate() {
+	raw := `{"@type":"Event","name":"GopherCon","startDate":"2024-05-17","organizer":{"name":"Gophers","foundingDate":"2012-03-28"}}`
+
+	var c jsonpoly.Container[Thing, *ThingJSONHelper]
+	err := json.Unmarshal([]byte(raw), &c)
+	if err != nil {
+		panic(err)
+	}
+	e := c.Value.(Event)
+	fmt.Println(e.StartDate.Weekday())
+	fmt.Println(e.Organizer.Founded.Year())
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+
+	// Output:
+	// Friday
+	// 2012
+	// {"@type":"Event","name":"GopherCon","startDate":"2024-05-17","organizer":{"name":"Gophers","foundingDate":"2012-03-28"}}
+}
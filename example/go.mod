@@ -0,0 +1,8 @@
+module github.com/lovromazgon/jsonpoly/example
+
+go 1.22.4
+
+require (
+	github.com/lovromazgon/jsonpoly v0.0.0-00010101000000-000000000000
+	sigs.k8s.io/yaml v1.4.0
+)
@@ -0,0 +1,45 @@
+package example
+
+import (
+	"encoding/json"
+)
+
+// InferredPolytopeJSONHelper determines a polytope based on its kind, the
+// dimension is not stored in the JSON object. Instead, it is inferred from
+// the number of coordinates of the first point, which is "top-left" for
+// hypercubes and "p0" for hyperpyramids.
+type InferredPolytopeJSONHelper struct {
+	Kind      string `json:"kind"`
+	dimension int
+}
+
+func (h *InferredPolytopeJSONHelper) ExtractType(b []byte) (string, error) {
+	var o struct {
+		Kind    string            `json:"kind"`
+		TopLeft []json.RawMessage `json:"top-left"`
+		P0      []json.RawMessage `json:"p0"`
+	}
+	if err := json.Unmarshal(b, &o); err != nil {
+		return "", err
+	}
+	switch o.Kind {
+	case Hypercube{}.Kind():
+		h.dimension = len(o.TopLeft)
+	case Hyperpyramid{}.Kind():
+		h.dimension = len(o.P0)
+	}
+	return o.Kind, nil
+}
+
+func (h *InferredPolytopeJSONHelper) Get() Polytope {
+	p, ok := PolytopeRegistry.Lookup(PolytopeKey{Kind: h.Kind, Dimension: h.dimension})
+	if !ok {
+		// The container reports the kind in the ErrUnknownType error.
+		return nil
+	}
+	return p
+}
+
+func (h *InferredPolytopeJSONHelper) Set(p Polytope) {
+	h.Kind = p.Kind()
+}
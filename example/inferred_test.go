@@ -0,0 +1,43 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+func ExampleInferredPolytopeJSONHelper() {
+	c := jsonpoly.New[Polytope, *InferredPolytopeJSONHelper](Cube{TopLeft: [3]int{1, 2, 3}, Width: 4})
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+
+	for _, raw := range []string{
+		`{"kind":"hypercube","top-left":[1,2],"width":4}`,
+		`{"kind":"hypercube","top-left":[1,2,3],"width":4}`,
+		`{"kind":"hyperpyramid","p0":[0,0],"p1":[1,0],"p2":[0,1]}`,
+		`{"kind":"hyperpyramid","p0":[0,0,0],"p1":[1,0,0],"p2":[0,1,0],"p3":[0,0,1]}`,
+	} {
+		var c jsonpoly.Container[Polytope, *InferredPolytopeJSONHelper]
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			panic(err)
+		}
+		fmt.Printf("%T %v\n", c.Value, c.Value.Dimension())
+	}
+
+	// There is no known hypercube with 4 dimensions.
+	err = json.Unmarshal([]byte(`{"kind":"hypercube","top-left":[0,0,0,0],"width":1}`), &c)
+	fmt.Println(err)
+
+	// Output:
+	// {"kind":"hypercube","top-left":[1,2,3],"width":4}
+	// example.Square 2
+	// example.Cube 3
+	// example.Triangle 2
+	// example.Pyramid 3
+	// unknown type {"kind":"hypercube"}
+}
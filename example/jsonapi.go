@@ -0,0 +1,69 @@
+package example
+
+import (
+	"github.com/lovromazgon/jsonpoly"
+)
+
+// Resource is a JSON:API resource object.
+type Resource interface {
+	ResourceType() string
+	ResourceID() string
+}
+
+func (a Article) ResourceType() string { return "articles" }
+func (a Article) ResourceID() string   { return a.ID }
+
+func (a Author) ResourceType() string { return "people" }
+func (a Author) ResourceID() string   { return a.ID }
+
+// Article is a JSON:API resource of type "articles". The ID is not part of
+// the attributes, it is handled by the helper.
+type Article struct {
+	ID    string `json:"-"`
+	Title string `json:"title"`
+}
+
+// Author is a JSON:API resource of type "people".
+type Author struct {
+	ID        string `json:"-"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+// ResourceJSONHelper determines a JSON:API resource based on its type. It
+// stores the attributes of the resource in an envelope under the key
+// "attributes" and handles the resource ID.
+type ResourceJSONHelper struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+}
+
+func (h *ResourceJSONHelper) Options() jsonpoly.Options {
+	return jsonpoly.Options{Envelope: true}
+}
+
+func (h *ResourceJSONHelper) DataKey() string {
+	return "attributes"
+}
+
+func (h *ResourceJSONHelper) Get() Resource {
+	switch h.Type {
+	case Article{}.ResourceType():
+		return Article{ID: h.ID}
+	case Author{}.ResourceType():
+		return Author{ID: h.ID}
+	}
+	return nil
+}
+
+func (h *ResourceJSONHelper) Set(r Resource) {
+	h.Type = r.ResourceType()
+	h.ID = r.ResourceID()
+}
+
+// Document is a JSON:API document containing a primary resource and included
+// resources.
+type Document struct {
+	Data     jsonpoly.Container[Resource, *ResourceJSONHelper]   `json:"data"`
+	Included []jsonpoly.Container[Resource, *ResourceJSONHelper] `json:"included,omitempty"`
+}
@@ -0,0 +1,29 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func ExampleDocument() {
+	raw := `{"data":{"type":"articles","id":"1","attributes":{"title":"JSON:API paints my bikeshed!"}},"included":[{"type":"people","id":"9","attributes":{"firstName":"Dan","lastName":"Gebhardt"}}]}`
+
+	var doc Document
+	err := json.Unmarshal([]byte(raw), &doc)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%#v\n", doc.Data.Value)
+	fmt.Printf("%#v\n", doc.Included[0].Value)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(b) == raw)
+
+	// Output:
+	// example.Article{ID:"1", Title:"JSON:API paints my bikeshed!"}
+	// example.Author{ID:"9", FirstName:"Dan", LastName:"Gebhardt"}
+	// true
+}
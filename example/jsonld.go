@@ -0,0 +1,58 @@
+package example
+
+// Thing represents a JSON-LD node with a type from the schema.org vocabulary.
+type Thing interface {
+	Type() string
+}
+
+func (Person) Type() string       { return "Person" }
+func (Organization) Type() string { return "Organization" }
+func (Event) Type() string        { return "Event" }
+
+// Person is a schema.org person.
+type Person struct {
+	Context string `json:"@context,omitempty"`
+	Name    string `json:"name"`
+	Email   string `json:"email,omitempty"`
+}
+
+// Organization is a schema.org organization.
+type Organization struct {
+	Context string `json:"@context,omitempty"`
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+}
+
+// Event is a schema.org event, the dates are formatted without the time.
+type Event struct {
+	Context   string     `json:"@context,omitempty"`
+	Name      string     `json:"name"`
+	StartDate Date       `json:"startDate"`
+	Organizer *Organizer `json:"organizer,omitempty"`
+}
+
+// Organizer is the organizer of an event.
+type Organizer struct {
+	Name    string `json:"name"`
+	Founded Date   `json:"foundingDate"`
+}
+
+var KnownThings = map[string]Thing{
+	Person{}.Type():       Person{},
+	Organization{}.Type(): Organization{},
+	Event{}.Type():        Event{},
+}
+
+// ThingJSONHelper determines a JSON-LD node based on its @type. Any other
+// JSON-LD keywords (e.g. @context) are left to the value.
+type ThingJSONHelper struct {
+	Type string `json:"@type"`
+}
+
+func (h *ThingJSONHelper) Get() Thing {
+	return KnownThings[h.Type]
+}
+
+func (h *ThingJSONHelper) Set(t Thing) {
+	h.Type = t.Type()
+}
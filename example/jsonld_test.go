@@ -0,0 +1,31 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+func ExampleThing() {
+	raw := `{"@context":"https://schema.org","@type":"Person","name":"Jane Doe","email":"jane@example.com"}`
+
+	var c jsonpoly.Container[Thing, *ThingJSONHelper]
+	err := json.Unmarshal([]byte(raw), &c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%T\n", c.Value)           // example.Person
+	fmt.Println(c.Value.(Person).Context) // https://schema.org
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+
+	// Output:
+	// example.Person
+	// https://schema.org
+	// {"@type":"Person","@context":"https://schema.org","name":"Jane Doe","email":"jane@example.com"}
+}
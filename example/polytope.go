@@ -1,5 +1,9 @@
 package example
 
+import (
+	"github.com/lovromazgon/jsonpoly"
+)
+
 // Polytope represents a polytope in a specific dimension.
 type Polytope interface {
 	Kind() string
@@ -51,32 +55,36 @@ type Pyramid struct {
 	P3 [3]int `json:"p3"`
 }
 
-var KnownPolytopes = map[string]map[int]Polytope{
-	Hyperpyramid{}.Kind(): {
-		Triangle{}.Dimension(): Triangle{},
-		Pyramid{}.Dimension():  Pyramid{},
-	},
-	Hypercube{}.Kind(): {
-		Square{}.Dimension(): Square{},
-		Cube{}.Dimension():   Cube{},
-	},
-}
-
-// PolytopeJSONHelper determines a polytope based on its kind and dimension.
-type PolytopeJSONHelper struct {
+// PolytopeKey identifies a polytope by its kind and dimension, which are the
+// discriminator fields of PolytopeJSONHelper.
+type PolytopeKey struct {
 	Kind      string `json:"kind"`
 	Dimension int    `json:"dimension"`
 }
 
-func (h *PolytopeJSONHelper) Get() Polytope {
-	s, ok := KnownPolytopes[h.Kind]
-	if !ok {
-		return nil
-	}
-	return s[h.Dimension]
+// PolytopeKeyOf returns the key of the polytope.
+func PolytopeKeyOf(p Polytope) PolytopeKey {
+	return PolytopeKey{Kind: p.Kind(), Dimension: p.Dimension()}
+}
+
+// PolytopeRegistry contains all known polytopes, registered under their kind
+// and dimension.
+var PolytopeRegistry = func() *jsonpoly.MultiKeyRegistry[PolytopeKey, Polytope] {
+	var r jsonpoly.MultiKeyRegistry[PolytopeKey, Polytope]
+	r.RegisterAll(PolytopeKeyOf, Triangle{}, Pyramid{}, Square{}, Cube{})
+	return &r
+}()
+
+// Polytopes provides PolytopeRegistry to PolytopeJSONHelper.
+type Polytopes struct{}
+
+func (Polytopes) Registry() *jsonpoly.MultiKeyRegistry[PolytopeKey, Polytope] {
+	return PolytopeRegistry
 }
 
-func (h *PolytopeJSONHelper) Set(s Polytope) {
-	h.Kind = s.Kind()
-	h.Dimension = s.Dimension()
+func (Polytopes) Key(p Polytope) PolytopeKey {
+	return PolytopeKeyOf(p)
 }
+
+// PolytopeJSONHelper determines a polytope based on its kind and dimension.
+type PolytopeJSONHelper = jsonpoly.MultiKeyHelper[PolytopeKey, Polytope, Polytopes]
@@ -10,7 +10,7 @@ import (
 func ExamplePolytope() {
 	inputPolytope := Square{TopLeft: [2]int{1, 2}, Width: 4}
 
-	c := jsonpoly.Container[Polytope, *PolytopeJSONHelper]{Value: inputPolytope}
+	c := jsonpoly.New[Polytope, *PolytopeJSONHelper](inputPolytope)
 
 	b, err := json.Marshal(c)
 	if err != nil {
@@ -29,3 +29,14 @@ func ExamplePolytope() {
 	// {"kind":"hypercube","dimension":2,"top-left":[1,2],"width":4}
 	// example.Square
 }
+
+func ExamplePolytope_unknown() {
+	raw := `{"kind":"hypercube","dimension":4,"top-left":[0,0,0,0],"width":1}`
+
+	var c jsonpoly.Container[Polytope, *PolytopeJSONHelper]
+	err := json.Unmarshal([]byte(raw), &c)
+	fmt.Println(err)
+
+	// Output:
+	// unknown type {"kind":"hypercube","dimension":4}
+}
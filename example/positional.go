@@ -0,0 +1,53 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PolytopeTag is a discriminator encoded positionally as a JSON array
+// containing the kind and the dimension, e.g. ["hypercube",2].
+type PolytopeTag struct {
+	Kind      string
+	Dimension int
+}
+
+func (t PolytopeTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{t.Kind, t.Dimension})
+}
+
+func (t *PolytopeTag) UnmarshalJSON(b []byte) error {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return err
+	}
+	if len(parts) != 2 {
+		return fmt.Errorf("expected tag with 2 elements, got %d", len(parts))
+	}
+	if err := json.Unmarshal(parts[0], &t.Kind); err != nil {
+		return fmt.Errorf("invalid kind: %w", err)
+	}
+	if err := json.Unmarshal(parts[1], &t.Dimension); err != nil {
+		return fmt.Errorf("invalid dimension: %w", err)
+	}
+	return nil
+}
+
+// PositionalPolytopeJSONHelper determines a polytope based on the positional
+// tag stored in the key "t".
+type PositionalPolytopeJSONHelper struct {
+	Tag PolytopeTag `json:"t"`
+}
+
+func (h *PositionalPolytopeJSONHelper) Get() Polytope {
+	p, ok := PolytopeRegistry.Lookup(PolytopeKey{Kind: h.Tag.Kind, Dimension: h.Tag.Dimension})
+	if !ok {
+		// The container reports the tag in the ErrUnknownType error.
+		return nil
+	}
+	return p
+}
+
+func (h *PositionalPolytopeJSONHelper) Set(p Polytope) {
+	h.Tag = PolytopeTag{Kind: p.Kind(), Dimension: p.Dimension()}
+}
@@ -0,0 +1,33 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+func ExamplePositionalPolytopeJSONHelper() {
+	c := jsonpoly.New[Polytope, *PositionalPolytopeJSONHelper](Cube{TopLeft: [3]int{1, 2, 3}, Width: 4})
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+
+	c.Value = nil
+	if err := json.Unmarshal([]byte(`{"t":["hyperpyramid",2],"p0":[0,0],"p1":[1,0],"p2":[0,1]}`), &c); err != nil {
+		panic(err)
+	}
+	fmt.Printf("%T\n", c.Value)
+
+	// The tag needs exactly two elements.
+	err = json.Unmarshal([]byte(`{"t":["hypercube"]}`), &c)
+	fmt.Println(err)
+
+	// Output:
+	// {"t":["hypercube",3],"top-left":[1,2,3],"width":4}
+	// example.Triangle
+	// expected tag with 2 elements, got 1
+}
@@ -0,0 +1,63 @@
+package example
+
+import (
+	"github.com/lovromazgon/jsonpoly"
+)
+
+// PolytopeUnion is a tagged union of all known polytopes. Only one field is
+// set at a time.
+type PolytopeUnion struct {
+	Square   *Square
+	Cube     *Cube
+	Triangle *Triangle
+	Pyramid  *Pyramid
+}
+
+// PolytopeUnionJSONHelper determines the field of the polytope union based on
+// the kind and dimension.
+type PolytopeUnionJSONHelper struct {
+	Kind      string `json:"kind"`
+	Dimension int    `json:"dimension"`
+}
+
+func (h *PolytopeUnionJSONHelper) Options() jsonpoly.Options {
+	return jsonpoly.Options{Union: true}
+}
+
+func (h *PolytopeUnionJSONHelper) Get() PolytopeUnion {
+	p, ok := PolytopeRegistry.Lookup(PolytopeKey{Kind: h.Kind, Dimension: h.Dimension})
+	if !ok {
+		// The container reports the kind and dimension in the
+		// ErrUnknownType error.
+		return PolytopeUnion{}
+	}
+	switch p.(type) {
+	case Square:
+		return PolytopeUnion{Square: &Square{}}
+	case Cube:
+		return PolytopeUnion{Cube: &Cube{}}
+	case Triangle:
+		return PolytopeUnion{Triangle: &Triangle{}}
+	case Pyramid:
+		return PolytopeUnion{Pyramid: &Pyramid{}}
+	}
+	return PolytopeUnion{}
+}
+
+func (h *PolytopeUnionJSONHelper) Set(u PolytopeUnion) {
+	var p Polytope
+	switch {
+	case u.Square != nil:
+		p = u.Square
+	case u.Cube != nil:
+		p = u.Cube
+	case u.Triangle != nil:
+		p = u.Triangle
+	case u.Pyramid != nil:
+		p = u.Pyramid
+	default:
+		return
+	}
+	h.Kind = p.Kind()
+	h.Dimension = p.Dimension()
+}
@@ -0,0 +1,31 @@
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+func ExamplePolytopeUnion() {
+	raw := `{"kind":"hyperpyramid","dimension":2,"p0":[0,0],"p1":[2,0],"p2":[1,1]}`
+
+	var c jsonpoly.Container[PolytopeUnion, *PolytopeUnionJSONHelper]
+	err := json.Unmarshal([]byte(raw), &c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(c.Value.Square == nil) // true
+	fmt.Println(c.Value.Triangle.P1)   // [2 0]
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", b)
+
+	// Output:
+	// true
+	// [2 0]
+	// {"kind":"hyperpyramid","dimension":2,"p0":[0,0],"p1":[2,0],"p2":[1,1]}
+}
@@ -0,0 +1,10 @@
+package example
+
+import "sigs.k8s.io/yaml"
+
+// YAML is a jsonpoly.Format for YAML documents. YAML is converted to JSON
+// before decoding, so the JSON struct tags of the values and helpers apply.
+type YAML struct{}
+
+func (YAML) ToJSON(b []byte) ([]byte, error)   { return yaml.YAMLToJSON(b) }
+func (YAML) FromJSON(b []byte) ([]byte, error) { return yaml.JSONToYAML(b) }
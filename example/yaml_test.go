@@ -0,0 +1,32 @@
+package example
+
+import (
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+func ExampleYAML() {
+	in := `{"kind":"hypercube","dimension":2,"top-left":[1,2],"width":4}`
+
+	out, err := jsonpoly.Transcode[Polytope, *PolytopeJSONHelper]([]byte(in), jsonpoly.JSON, YAML{})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s", out)
+
+	out, err = jsonpoly.Transcode[Polytope, *PolytopeJSONHelper](out, YAML{}, jsonpoly.JSON)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s\n", out)
+
+	// Output:
+	// dimension: 2
+	// kind: hypercube
+	// top-left:
+	// - 1
+	// - 2
+	// width: 4
+	// {"kind":"hypercube","dimension":2,"top-left":[1,2],"width":4}
+}
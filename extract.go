@@ -0,0 +1,49 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TypeExtractor is an optional interface a Helper can implement to extract
+// the discriminator from the input itself, e.g. from a nested path or using a
+// regular expression, instead of the container unmarshalling the JSON object
+// into the helper. This also avoids parsing the object just to get the
+// discriminator.
+//
+// ExtractType is called on a zero helper with the whole JSON object, the
+// returned string is then stored in the only field of the helper (the helper
+// needs to have exactly one key) and Get is called as usual. If the returned
+// string is empty, the helper is left with the zero value. ExtractType can
+// also populate fields of the helper that are inferred from the input but are
+// not marshalled (e.g. unexported fields), those are kept. DiscriminatorMapper
+// is not applied to the extracted type.
+type TypeExtractor interface {
+	ExtractType(b []byte) (string, error)
+}
+
+// extractHelper populates the helper with the type returned by ExtractType
+// and reports whether a type was found.
+func extractHelper(b []byte, helper any, e TypeExtractor) (present bool, err error) {
+	typ, err := e.ExtractType(b)
+	if err != nil || typ == "" {
+		return false, err
+	}
+	keys, err := discriminatorKeys(helper)
+	if err != nil {
+		return false, err
+	}
+	if len(keys) != 1 {
+		return false, fmt.Errorf("helper %T needs to have exactly one key, got %v", helper, keys)
+	}
+
+	t, err := json.Marshal(typ)
+	if err != nil {
+		return false, err
+	}
+	o, err := writeObject([]objectField{{key: keys[0], value: t}})
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(o, helper)
+}
@@ -0,0 +1,109 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// MetaKindHelper reads the type from the nested path meta.kind.
+type MetaKindHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *MetaKindHelper) ExtractType(b []byte) (string, error) {
+	var o struct {
+		Meta *struct {
+			Kind string `json:"kind"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(b, &o); err != nil {
+		return "", err
+	}
+	if o.Meta == nil {
+		return "", nil
+	}
+	return o.Meta.Kind, nil
+}
+
+func (h *MetaKindHelper) Get() Animal {
+	switch h.Type {
+	case "dog":
+		return Dog{}
+	case "cat":
+		return Cat{}
+	}
+	return nil
+}
+
+func (h *MetaKindHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+// FailingExtractorHelper rejects every input.
+type FailingExtractorHelper struct {
+	MetaKindHelper
+}
+
+var errNoType = errors.New("no type")
+
+func (h *FailingExtractorHelper) ExtractType([]byte) (string, error) {
+	return "", errNoType
+}
+
+// VersionedExtractorHelper has more than one key, so the extracted type can
+// not be stored.
+type VersionedExtractorHelper struct {
+	MetaKindHelper
+	Version int `json:"version"`
+}
+
+func TestTypeExtractor(t *testing.T) {
+	const have = `{"meta":{"kind":"dog"},"name":"Fido","breed":"Pug"}`
+
+	var c Container[Animal, *MetaKindHelper]
+	present, err := c.UnmarshalWithPresence([]byte(have))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !present {
+		t.Fatal("expected the type to be present")
+	}
+	if want := (Dog{XName: "Fido", Breed: "Pug"}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	t.Run("top_level_ignored", func(t *testing.T) {
+		var c Container[Animal, *MetaKindHelper]
+		err := c.UnmarshalJSON([]byte(`{"type":"dog","meta":{"kind":"cat"},"name":"Tom"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Cat{XName: "Tom"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		var c Container[Animal, *MetaKindHelper]
+		err := c.UnmarshalJSON([]byte(`{"name":"Fido"}`))
+		if !errors.Is(err, ErrMissingType) {
+			t.Fatalf("want %v, got %v", ErrMissingType, err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var c Container[Animal, *FailingExtractorHelper]
+		err := c.UnmarshalJSON([]byte(have))
+		if !errors.Is(err, errNoType) {
+			t.Fatalf("want %v, got %v", errNoType, err)
+		}
+	})
+
+	t.Run("multiple_keys", func(t *testing.T) {
+		var c Container[Animal, *VersionedExtractorHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
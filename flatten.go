@@ -0,0 +1,52 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FlattenInto marshals the container and adds its top-level fields to parent,
+// so they can be marshalled as part of the parent object instead of nested
+// under a field. This is needed because encoding/json does not flatten fields
+// implementing json.Marshaler, not even embedded ones. A parent type can use
+// it in its MarshalJSON method:
+//
+//	func (o Owner) MarshalJSON() ([]byte, error) {
+//		m := map[string]json.RawMessage{}
+//		m["owner"], _ = json.Marshal(o.Name)
+//		if err := o.Pet.FlattenInto(m); err != nil {
+//			return nil, err
+//		}
+//		return json.Marshal(m)
+//	}
+//
+// Note that encoding/json sorts the keys of a map, so the discriminator is not
+// necessarily the first field in the output. If the parent already contains a
+// key produced by the container, ErrDuplicateKey is returned and parent is
+// left unchanged. If the container is marshalled as null, nothing is added.
+// The flattened object can be unmarshalled by passing the whole parent object
+// to Container.UnmarshalJSON, as long as the value ignores the fields of the
+// parent.
+func (c Container[V, H]) FlattenInto(parent map[string]json.RawMessage) error {
+	b, err := c.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if string(b) == "null" {
+		return nil
+	}
+
+	fields, err := parseObject(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if _, ok := parent[f.key]; ok {
+			return fmt.Errorf("%w %q", ErrDuplicateKey, f.key)
+		}
+	}
+	for _, f := range fields {
+		parent[f.key] = f.value
+	}
+	return nil
+}
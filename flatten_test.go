@@ -0,0 +1,83 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// PetOwner flattens the fields of the pet into its own object.
+type PetOwner struct {
+	Owner string
+	Pet   Container[Animal, *AnimalContainerHelper]
+}
+
+func (o PetOwner) MarshalJSON() ([]byte, error) {
+	m := map[string]json.RawMessage{}
+	m["owner"], _ = json.Marshal(o.Owner)
+	if err := o.Pet.FlattenInto(m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (o *PetOwner) UnmarshalJSON(b []byte) error {
+	var flat struct {
+		Owner string `json:"owner"`
+	}
+	if err := json.Unmarshal(b, &flat); err != nil {
+		return err
+	}
+	o.Owner = flat.Owner
+	return o.Pet.UnmarshalJSON(b)
+}
+
+func TestContainer_FlattenInto(t *testing.T) {
+	t.Run("round_trip", func(t *testing.T) {
+		have := PetOwner{
+			Owner: "Alice",
+			Pet:   New[Animal, *AnimalContainerHelper](Dog{XName: "Fido", Breed: "Pug"}),
+		}
+		want := `{"breed":"Pug","name":"Fido","owner":"Alice","type":"dog"}`
+
+		got, err := json.Marshal(have)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+
+		var o PetOwner
+		if err := json.Unmarshal(got, &o); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(o, have) {
+			t.Fatalf("want %v, got %v", have, o)
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		got, err := json.Marshal(PetOwner{Owner: "Alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"owner":"Alice"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("duplicate_key", func(t *testing.T) {
+		parent := map[string]json.RawMessage{"name": json.RawMessage(`"Alice"`)}
+		c := New[Animal, *AnimalContainerHelper](Cat{XName: "Tom"})
+		err := c.FlattenInto(parent)
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("want %v, got %v", ErrDuplicateKey, err)
+		}
+		want := map[string]json.RawMessage{"name": json.RawMessage(`"Alice"`)}
+		if !reflect.DeepEqual(parent, want) {
+			t.Fatalf("want parent to be unchanged, got %v", parent)
+		}
+	})
+}
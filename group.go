@@ -0,0 +1,121 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// GroupedMarshal marshals the values into a JSON object keyed by the
+// discriminator of each value, e.g. {"dog":{"name":"Fido"},"cat":{...}}. The
+// fields are emitted in the order of the values. The helper needs to have
+// exactly one key with a string value. If two values have the same
+// discriminator, ErrDuplicateKey is returned.
+func GroupedMarshal[V any, H Helper[V]](values []V) ([]byte, error) {
+	key, err := discriminatorKey[V, H]()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]objectField, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for i, v := range values {
+		if isNil(v) {
+			return nil, fmt.Errorf("value at index %d is nil", i)
+		}
+		b, err := Container[V, H]{Value: v}.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		fields, err := parseObject(b)
+		if err != nil {
+			return nil, err
+		}
+
+		// Extract the discriminator, the rest of the fields are the value.
+		var typ string
+		var found bool
+		for j, f := range fields {
+			if !strings.EqualFold(f.key, key) {
+				continue
+			}
+			if err := json.Unmarshal(f.value, &typ); err != nil {
+				return nil, fmt.Errorf("discriminator %q is not a string: %w", key, err)
+			}
+			fields = append(fields[:j], fields[j+1:]...)
+			found = true
+			break
+		}
+		if !found {
+			return nil, ErrMissingType
+		}
+		if seen[typ] {
+			return nil, fmt.Errorf("%w %q", ErrDuplicateKey, typ)
+		}
+		seen[typ] = true
+
+		value, err := writeObject(fields)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, objectField{key: typ, value: value})
+	}
+	return writeObject(groups)
+}
+
+// GroupedUnmarshal is the inverse of GroupedMarshal, it unmarshals a JSON
+// object keyed by the discriminator into a slice of values. The values are
+// returned in the order in which they appear in the object.
+func GroupedUnmarshal[V any, H Helper[V]](b []byte) ([]V, error) {
+	key, err := discriminatorKey[V, H]()
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if !isJSONObject(b) {
+		return nil, ErrNotJSONObject
+	}
+
+	groups, err := parseObject(b)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]V, 0, len(groups))
+	for _, g := range groups {
+		// Add the discriminator back into the object of the value.
+		typ, err := json.Marshal(g.key)
+		if err != nil {
+			return nil, err
+		}
+		o, err := writeObject([]objectField{{key: key, value: typ}})
+		if err != nil {
+			return nil, err
+		}
+		if o, err = mergeJSONObjects(o, g.value); err != nil {
+			return nil, err
+		}
+
+		var c Container[V, H]
+		if err := c.UnmarshalJSON(o); err != nil {
+			return nil, err
+		}
+		values = append(values, c.Value)
+	}
+	return values, nil
+}
+
+// discriminatorKey returns the only JSON key of the helper H. It returns an
+// error if the helper has more than one key or its keys are not known.
+func discriminatorKey[V any, H Helper[V]]() (string, error) {
+	t := reflect.TypeFor[H]()
+	if t.Kind() != reflect.Ptr {
+		return "", ErrHelperNotPointer
+	}
+	keys, ok := helperKeys(t.Elem())
+	if !ok || len(keys) != 1 {
+		return "", fmt.Errorf("helper %v needs to have exactly one key, got %v", t, keys)
+	}
+	return keys[0], nil
+}
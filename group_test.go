@@ -0,0 +1,90 @@
+package jsonpoly
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGroupedMarshal(t *testing.T) {
+	have := []Animal{
+		Dog{XName: "Fido", Breed: "Pug"},
+		Cat{XName: "Tom", Owner: "Alice", Color: "black"},
+		Bird{XName: "Tweety", XType: "bird"},
+	}
+	want := `{"dog":{"name":"Fido","breed":"Pug"},"cat":{"name":"Tom","owner":"Alice","color":"black"},"bird":{"name":"Tweety"}}`
+
+	got, err := GroupedMarshal[Animal, *AnimalContainerHelper](have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	values, err := GroupedUnmarshal[Animal, *AnimalContainerHelper](got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, have) {
+		t.Fatalf("want %v, got %v", have, values)
+	}
+
+	values, err = GroupedUnmarshal[Animal, *AnimalContainerHelper]([]byte(" \n" + want + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, have) {
+		t.Fatalf("want %v with surrounding whitespace, got %v", have, values)
+	}
+}
+
+func TestGroupedMarshal_error(t *testing.T) {
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := GroupedMarshal[Animal, *AnimalContainerHelper]([]Animal{Dog{}, Cat{}, Dog{}})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("want %v, got %v", ErrDuplicateKey, err)
+		}
+	})
+	t.Run("nil", func(t *testing.T) {
+		_, err := GroupedMarshal[Animal, *AnimalContainerHelper]([]Animal{Dog{}, nil})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+	t.Run("multiple_keys", func(t *testing.T) {
+		_, err := GroupedMarshal[Animal, *VersionedAnimalHelper]([]Animal{Dog{}})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestGroupedUnmarshal_error(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    string
+		wantErr error
+	}{{
+		name:    "not_object",
+		have:    `[]`,
+		wantErr: ErrNotJSONObject,
+	}, {
+		name:    "value_not_object",
+		have:    `{"dog":1}`,
+		wantErr: ErrNotJSONObject,
+	}, {
+		name:    "unknown_type",
+		have:    `{"dog":{"name":"Fido"},"dolphin":{}}`,
+		wantErr: ErrUnknownType,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GroupedUnmarshal[Animal, *AnimalRegistryHelper]([]byte(tc.have))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
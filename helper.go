@@ -0,0 +1,235 @@
+package jsonpoly
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// HelperInitializer is an optional interface a Helper can implement if the
+// zero value of the helper is not usable (e.g. it holds a reference to a
+// registry). Init is called on every newly allocated helper before it is used.
+type HelperInitializer interface {
+	Init()
+}
+
+// newHelper allocates a new helper. The helper type needs to be a pointer,
+// otherwise it could not be populated by Set or when unmarshalling. If the
+// helper implements HelperInitializer, it is initialized.
+func newHelper[V any, H Helper[V]]() (H, error) {
+	t := reflect.TypeFor[H]()
+	if t.Kind() != reflect.Ptr {
+		var zero H
+		return zero, ErrHelperNotPointer
+	}
+	helper := reflect.New(t.Elem()).Interface().(H)
+	if initializer, ok := any(helper).(HelperInitializer); ok {
+		initializer.Init()
+	}
+	return helper, nil
+}
+
+// ValidateHelper checks that H is a usable helper for Container[V, H], so
+// misconfigured helpers can be caught at startup (e.g. in init or in a test)
+// instead of when the first value is marshalled or unmarshalled. It checks
+// that H is a pointer type, that Get does not panic on a zero helper, that
+// Set does not panic when receiving the value returned by Get, and that the
+// helper can be marshalled.
+func ValidateHelper[V any, H Helper[V]]() error {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return err
+	}
+
+	var v V
+	if err := recoverPanic(reflect.TypeFor[H](), "Get", func() { v = helper.Get() }); err != nil {
+		return err
+	}
+	if !isNil(v) {
+		helper, _ = newHelper[V, H]()
+		if err := recoverPanic(reflect.TypeFor[H](), "Set", func() { helper.Set(v) }); err != nil {
+			return err
+		}
+	}
+
+	if _, err := json.Marshal(helper); err != nil {
+		return fmt.Errorf("helper %v can not be marshalled: %w", reflect.TypeFor[H](), err)
+	}
+	return nil
+}
+
+// recoverPanic calls fn and returns an error if it panics.
+func recoverPanic(t reflect.Type, method string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("helper %v: %s panicked: %v", t, method, r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// unmarshalHelper unmarshals the JSON object into the helper and reports
+// whether any of the keys of the helper were present in the object. If the
+// keys of the helper are known, only those fields are extracted from the
+// object before unmarshalling, which avoids parsing the whole object just to
+// get the discriminator. If the helper implements TypeExtractor, it is used
+// instead. A key with a null value is not present, the same as in
+// hasDiscriminator. The presence is only reported if wantPresence is true,
+// since finding the keys can require marshalling the helper.
+func unmarshalHelper(b []byte, helper any, wantPresence bool) (present bool, err error) {
+	if e, ok := helper.(TypeExtractor); ok {
+		return extractHelper(b, helper, e)
+	}
+	if keys, ok := helperKeys(reflect.TypeOf(helper).Elem()); ok {
+		if b, err = selectFields(b, keys); err != nil {
+			return false, err
+		}
+		if wantPresence {
+			if present, err = hasNonNullField(b, keys); err != nil {
+				return false, err
+			}
+		}
+	} else if wantPresence {
+		// The helper is still zero, so marshalling it returns its keys. If
+		// it can't be marshalled, the presence is simply not reported.
+		if keys, err := discriminatorKeys(helper); err == nil {
+			if present, err = hasNonNullField(b, keys); err != nil {
+				return false, err
+			}
+		}
+	}
+	if m, ok := helper.(DiscriminatorMapper); ok {
+		if b, err = mapStringFields(b, m.FromWire); err != nil {
+			return false, err
+		}
+	}
+	return present, json.Unmarshal(b, helper)
+}
+
+// hasNonNullField reports whether the JSON object contains any of the keys
+// with a value other than null. Keys are matched case-insensitively.
+func hasNonNullField(o []byte, keys []string) (bool, error) {
+	var found bool
+	err := scanObject(o, func(key, value []byte) error {
+		found = found || (containsKeyFold(keys, key) && string(value) != "null")
+		return nil
+	})
+	return found, err
+}
+
+// DiscriminatorMapper is an optional interface a Helper can implement to
+// transform the values of its string fields on the wire, e.g. to prefix the
+// discriminator with a namespace ("animal/dog"), while Get and Set work with
+// the plain values ("dog").
+type DiscriminatorMapper interface {
+	// ToWire returns the value of the field with the given key as it should
+	// be marshalled.
+	ToWire(key, value string) string
+	// FromWire reverses ToWire, it returns the value of the field with the
+	// given key as it should be unmarshalled into the helper. An error
+	// should be returned if the value is not valid on the wire.
+	FromWire(key, value string) (string, error)
+}
+
+// mapStringFields replaces the top-level string fields of the JSON object
+// with the values returned by fn. Other fields are copied as they are.
+func mapStringFields(o []byte, fn func(key, value string) (string, error)) ([]byte, error) {
+	fields, err := parseObject(o)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		var v string
+		if len(f.value) == 0 || f.value[0] != '"' {
+			continue
+		}
+		if err := json.Unmarshal(f.value, &v); err != nil {
+			return nil, err
+		}
+		if v, err = fn(f.key, v); err != nil {
+			return nil, err
+		}
+		if fields[i].value, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return writeObject(fields)
+}
+
+// discriminatorKeys returns the JSON keys of the helper. If the keys are not
+// statically known, the helper is marshalled to determine them.
+func discriminatorKeys(helper any) ([]string, error) {
+	if keys, ok := helperKeys(reflect.TypeOf(helper).Elem()); ok {
+		return keys, nil
+	}
+	b, err := json.Marshal(helper)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseObject(b)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.key
+	}
+	return keys, nil
+}
+
+var helperKeysCache sync.Map // map[reflect.Type][]string
+
+// helperKeys returns the JSON keys of the fields in the helper struct type t.
+// The keys are not known if the helper is not a struct or if it implements
+// custom unmarshalling, in which case ok is false.
+func helperKeys(t reflect.Type) (keys []string, ok bool) {
+	if cached, ok := helperKeysCache.Load(t); ok {
+		keys, _ := cached.([]string)
+		return keys, keys != nil
+	}
+
+	if t.Kind() == reflect.Struct &&
+		!reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) &&
+		!reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		keys = appendStructKeys(make([]string, 0, t.NumField()), t)
+	}
+	helperKeysCache.Store(t, keys)
+	return keys, keys != nil
+}
+
+// appendStructKeys appends the JSON keys of the struct fields in t to keys,
+// following the same rules as encoding/json, including fields of embedded
+// structs.
+func appendStructKeys(keys []string, t reflect.Type) []string {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				keys = appendStructKeys(keys, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
@@ -0,0 +1,134 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// PanickingGetHelper dereferences a registry that was never set.
+type PanickingGetHelper struct {
+	Type string `json:"type"`
+
+	registry *Registry[Animal]
+}
+
+func (h *PanickingGetHelper) Get() Animal {
+	a, _ := h.registry.Lookup(h.Type)
+	return a
+}
+
+func (h *PanickingGetHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+// PanickingSetHelper only supports dogs in Set.
+type PanickingSetHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *PanickingSetHelper) Set(a Animal) {
+	h.Type = a.(Dog).Type()
+}
+
+// ChannelHelper can not be marshalled.
+type ChannelHelper struct {
+	AnimalContainerHelper
+	C chan int `json:"c"`
+}
+
+func TestValidateHelper(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		wantErr string
+	}{{
+		name: "valid",
+		err:  ValidateHelper[Animal, *AnimalContainerHelper](),
+	}, {
+		name: "valid_registry",
+		err:  ValidateHelper[Animal, *AnimalRegistryHelper](),
+	}, {
+		name: "valid_initializer",
+		err:  ValidateHelper[Animal, *RegistryRefHelper](),
+	}, {
+		name:    "not_pointer",
+		err:     ValidateHelper[Animal, AnimalValueHelper](),
+		wantErr: ErrHelperNotPointer.Error(),
+	}, {
+		name:    "get_panics",
+		err:     ValidateHelper[Animal, *PanickingGetHelper](),
+		wantErr: "Get panicked",
+	}, {
+		name:    "set_panics",
+		err:     ValidateHelper[Animal, *PanickingSetHelper](),
+		wantErr: "Set panicked",
+	}, {
+		name:    "not_marshallable",
+		err:     ValidateHelper[Animal, *ChannelHelper](),
+		wantErr: "can not be marshalled",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.wantErr == "" {
+				if tc.err != nil {
+					t.Fatalf("unexpected error: %v", tc.err)
+				}
+				return
+			}
+			if tc.err == nil || !strings.Contains(tc.err.Error(), tc.wantErr) {
+				t.Fatalf("want error containing %q, got %v", tc.wantErr, tc.err)
+			}
+		})
+	}
+
+	if err := ValidateHelper[Animal, AnimalValueHelper](); !errors.Is(err, ErrHelperNotPointer) {
+		t.Fatalf("want %v, got %v", ErrHelperNotPointer, err)
+	}
+}
+
+// NamespacedAnimalHelper prefixes the type with a namespace on the wire.
+type NamespacedAnimalHelper struct {
+	AnimalRegistryHelper
+}
+
+func (h *NamespacedAnimalHelper) ToWire(key, value string) string {
+	return "animal/" + value
+}
+
+func (h *NamespacedAnimalHelper) FromWire(key, value string) (string, error) {
+	v, ok := strings.CutPrefix(value, "animal/")
+	if !ok {
+		return "", fmt.Errorf("%s %q is not in the animal namespace", key, value)
+	}
+	return v, nil
+}
+
+func TestDiscriminatorMapper(t *testing.T) {
+	testRoundTrip[Animal, *NamespacedAnimalHelper](
+		t,
+		Dog{XName: "Fido", Breed: "Pug"},
+		`{"type":"animal/dog","name":"Fido","breed":"Pug"}`,
+	)
+
+	t.Run("alias", func(t *testing.T) {
+		var c Container[Animal, *NamespacedAnimalHelper]
+		if err := json.Unmarshal([]byte(`{"type":"animal/k9","name":"Rex"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if want := (Dog{XName: "Rex"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("not_namespaced", func(t *testing.T) {
+		var c Container[Animal, *NamespacedAnimalHelper]
+		err := json.Unmarshal([]byte(`{"type":"dog","name":"Fido"}`), &c)
+		if err == nil || !strings.Contains(err.Error(), "not in the animal namespace") {
+			t.Fatalf("expected namespace error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package jsonpoly
+
+import "bytes"
+
+// stripJSONC converts a JSONC document into JSON by removing line and block
+// comments, as well as trailing commas in objects and arrays. Strings are
+// copied as they are, so they can contain anything that looks like a comment.
+func stripJSONC(b []byte) ([]byte, error) {
+	out := make([]byte, 0, len(b))
+	// comma is the index in out of the last comma that was not yet followed
+	// by anything but whitespace and comments, or -1.
+	comma := -1
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '"':
+			end, err := skipString(b, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b[i:end]...)
+			comma = -1
+			i = end
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			// Skip until the end of the line, the newline itself is kept.
+			end := bytes.IndexByte(b[i:], '\n')
+			if end < 0 {
+				end = len(b) - i
+			}
+			i += end
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := bytes.Index(b[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, invalidJSONError(i)
+			}
+			// Replace the comment with a space, so it still separates tokens.
+			out = append(out, ' ')
+			i += end + 4
+		case c == ',':
+			comma = len(out)
+			out = append(out, c)
+			i++
+		case (c == '}' || c == ']') && comma >= 0:
+			out[comma] = ' '
+			comma = -1
+			out = append(out, c)
+			i++
+		default:
+			if !isSpace(c) {
+				comma = -1
+			}
+			out = append(out, c)
+			i++
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,50 @@
+// Package jsonpolyproto provides a jsonpoly.Codec for protobuf messages, which
+// marshals and unmarshals them using protojson instead of encoding/json.
+package jsonpolyproto
+
+import (
+	"fmt"
+
+	"github.com/lovromazgon/jsonpoly"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec is a jsonpoly.Codec that uses protojson to marshal and unmarshal
+// values, which need to implement proto.Message. Set it in Options.Codec of a
+// helper to get protojson semantics (e.g. camelCase field names and the JSON
+// mapping of well-known types) for the value, while the discriminator is
+// still handled by the helper.
+type Codec struct {
+	MarshalOptions   protojson.MarshalOptions
+	UnmarshalOptions protojson.UnmarshalOptions
+}
+
+var _ jsonpoly.Codec = Codec{}
+
+// NewCodec returns a Codec with the default options, except that unknown
+// fields are discarded when unmarshalling. This is needed, since the JSON
+// object contains the discriminator keys, which are not fields of the message.
+func NewCodec() Codec {
+	return Codec{
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}
+}
+
+// Marshal marshals the message v using protojson.
+func (c Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("jsonpolyproto: %T is not a proto.Message", v)
+	}
+	return c.MarshalOptions.Marshal(m)
+}
+
+// Unmarshal unmarshals b into the message v using protojson.
+func (c Codec) Unmarshal(b []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("jsonpolyproto: %T is not a proto.Message", v)
+	}
+	return c.UnmarshalOptions.Unmarshal(b, m)
+}
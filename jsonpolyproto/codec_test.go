@@ -0,0 +1,110 @@
+package jsonpolyproto
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lovromazgon/jsonpoly"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/apipb"
+)
+
+// APIElementHelper determines the type of an API element based on the "kind"
+// key.
+type APIElementHelper struct {
+	Kind string `json:"kind"`
+}
+
+func (h *APIElementHelper) Options() jsonpoly.Options {
+	return jsonpoly.Options{Codec: NewCodec()}
+}
+
+func (h *APIElementHelper) Get() proto.Message {
+	switch h.Kind {
+	case "method":
+		return &apipb.Method{}
+	case "mixin":
+		return &apipb.Mixin{}
+	}
+	return nil
+}
+
+func (h *APIElementHelper) Set(m proto.Message) {
+	switch m.(type) {
+	case *apipb.Method:
+		h.Kind = "method"
+	case *apipb.Mixin:
+		h.Kind = "mixin"
+	}
+}
+
+func TestCodec(t *testing.T) {
+	testCases := []struct {
+		name string
+		have proto.Message
+	}{{
+		name: "method",
+		have: &apipb.Method{
+			Name:              "GetDog",
+			RequestTypeUrl:    "type.googleapis.com/example.GetDogRequest",
+			ResponseStreaming: true,
+		},
+	}, {
+		name: "mixin",
+		have: &apipb.Mixin{Name: "example.Animals", Root: "animals"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := jsonpoly.New[proto.Message, *APIElementHelper](tc.have)
+			b, err := json.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got jsonpoly.Container[proto.Message, *APIElementHelper]
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got.Value, tc.have) {
+				t.Fatalf("want %v, got %v", tc.have, got.Value)
+			}
+		})
+	}
+}
+
+func TestCodec_camelCase(t *testing.T) {
+	raw := `{"kind":"method","name":"GetDog","requestTypeUrl":"example.GetDogRequest"}`
+
+	var c jsonpoly.Container[proto.Message, *APIElementHelper]
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		t.Fatal(err)
+	}
+	want := &apipb.Method{Name: "GetDog", RequestTypeUrl: "example.GetDogRequest"}
+	if !proto.Equal(c.Value, want) {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output of protojson is deliberately unstable in whitespace, so we
+	// compare the decoded fields.
+	var m map[string]any
+	if err := json.Unmarshal(got, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["kind"] != "method" || m["requestTypeUrl"] != "example.GetDogRequest" {
+		t.Fatalf("unexpected output %s", got)
+	}
+}
+
+func TestCodec_notMessage(t *testing.T) {
+	if _, err := NewCodec().Marshal(struct{}{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := NewCodec().Unmarshal([]byte(`{}`), &struct{}{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/lovromazgon/jsonpoly/jsonpolyproto
+
+go 1.22.4
+
+require (
+	github.com/lovromazgon/jsonpoly v0.0.0-00010101000000-000000000000
+	google.golang.org/protobuf v1.36.4
+)
@@ -0,0 +1,290 @@
+// Package jsonpolyquery decodes and encodes polymorphic values from and to
+// query strings and HTML forms (url.Values), e.g. ?type=dog&name=Fido. It uses
+// the same helpers as jsonpoly.Container, the discriminator is read from the
+// same keys as in JSON.
+package jsonpolyquery
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+// ErrNotFlat is returned by Encode if the value contains nested objects, which
+// can not be represented in url.Values.
+var ErrNotFlat = errors.New("jsonpolyquery: value contains nested objects")
+
+// Decode decodes the polymorphic value from the query values. The values are
+// converted into a JSON object, which is then unmarshalled the same way as by
+// jsonpoly.Container, including the options of the helper. Since all query
+// values are strings, they are converted based on the types of the fields in
+// the helper and in the value returned by Helper.Get: numbers and booleans are
+// converted into JSON numbers and booleans, slices receive all values of a
+// key, while other fields only receive the first value. Keys are matched to
+// fields the same way as by encoding/json, only top-level fields are
+// supported. Since keys are matched case-insensitively, keys that only
+// differ in case (e.g. type and Type) are rejected with
+// jsonpoly.ErrDuplicateKey.
+func Decode[V any, H jsonpoly.Helper[V]](q url.Values) (V, error) {
+	var zero V
+	t := reflect.TypeFor[H]()
+	if t.Kind() != reflect.Ptr {
+		return zero, jsonpoly.ErrHelperNotPointer
+	}
+
+	// The helper is unmarshalled first, to find out the type of the value.
+	helper := reflect.New(t.Elem()).Interface().(H)
+	if initializer, ok := any(helper).(jsonpoly.HelperInitializer); ok {
+		initializer.Init()
+	}
+	b, err := toJSON(q, t)
+	if err != nil {
+		return zero, err
+	}
+	if err := json.Unmarshal(b, helper); err != nil {
+		return zero, err
+	}
+
+	types := []reflect.Type{t}
+	if v := helper.Get(); any(v) != nil {
+		types = append(types, reflect.TypeOf(v))
+	}
+	if b, err = toJSON(q, types...); err != nil {
+		return zero, err
+	}
+	return jsonpoly.Decode[V](helper, b)
+}
+
+// Encode encodes the polymorphic value into query values, using the JSON
+// object produced by jsonpoly.Container. Strings are added as they are,
+// numbers and booleans in their JSON representation, arrays as multiple values
+// of the same key and null values are omitted. If the object contains nested
+// objects, ErrNotFlat is returned.
+func Encode[V any, H jsonpoly.Helper[V]](v V) (url.Values, error) {
+	b, err := jsonpoly.New[V, H](v).MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	q := make(url.Values, len(fields))
+	for key, raw := range fields {
+		if raw[0] != '[' {
+			if err := addValue(q, key, raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return nil, err
+		}
+		for _, elem := range elems {
+			if err := addValue(q, key, elem); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return q, nil
+}
+
+// addValue adds the scalar JSON value raw to q under key.
+func addValue(q url.Values, key string, raw json.RawMessage) error {
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		q.Add(key, s)
+	case '{', '[':
+		return fmt.Errorf("%w: key %q", ErrNotFlat, key)
+	case 'n':
+		// Null values are omitted.
+	default:
+		q.Add(key, string(raw))
+	}
+	return nil
+}
+
+// toJSON converts the query values into a JSON object. The values are
+// converted based on the type of the field with the same key in the first of
+// types containing it. The keys are written in sorted order, keys that only
+// differ in case are rejected, since it would be undefined which of them
+// ends up in the field.
+func toJSON(q url.Values, types ...reflect.Type) ([]byte, error) {
+	keys := make([]string, 0, len(q))
+	for key, values := range q {
+		if len(values) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	seen := make(map[string]string, len(keys))
+	for i, key := range keys {
+		values := q[key]
+		lower := strings.ToLower(key)
+		if prev, ok := seen[lower]; ok {
+			return nil, fmt.Errorf("%w %q and %q", jsonpoly.ErrDuplicateKey, prev, key)
+		}
+		seen[lower] = key
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+
+		ft := fieldType(key, types)
+		if ft != nil && (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) && !isText(ft) {
+			buf.WriteByte('[')
+			for i, v := range values {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := writeValue(&buf, v, ft.Elem()); err != nil {
+					return nil, err
+				}
+			}
+			buf.WriteByte(']')
+			continue
+		}
+		if err := writeValue(&buf, values[0], ft); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeValue writes the query value s as JSON, converted based on the type t
+// of the field. If t is nil or not a number or boolean, s is written as a JSON
+// string. Values that are not valid numbers or booleans are written as
+// strings as well, so unmarshalling returns a descriptive error.
+func writeValue(buf *bytes.Buffer, s string, t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && !isText(t) {
+		switch t.Kind() {
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(s); err == nil {
+				buf.WriteString(strconv.FormatBool(b))
+				return nil
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if isNumber(s) {
+				buf.WriteString(s)
+				return nil
+			}
+		}
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// isNumber reports whether s is a valid JSON number.
+func isNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
+
+// isText reports whether values of type t are unmarshalled from JSON strings
+// by custom unmarshalling (e.g. time.Time), so they should not be converted.
+func isText(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(reflect.TypeFor[json.Unmarshaler]()) ||
+		pt.Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+}
+
+// fieldType returns the type of the struct field with the JSON key in the
+// first of types containing it, or nil if there is no such field.
+func fieldType(key string, types []reflect.Type) reflect.Type {
+	for _, t := range types {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			continue
+		}
+		if ft := structFieldType(key, t); ft != nil {
+			return ft
+		}
+	}
+	return nil
+}
+
+// structFieldType returns the type of the field with the JSON key in the
+// struct type t, including fields of embedded structs. An exact match is
+// preferred over a case-insensitive one, the same as in encoding/json.
+func structFieldType(key string, t reflect.Type) reflect.Type {
+	var fold reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if typ := structFieldType(key, ft); typ != nil {
+					return typ
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		typ := f.Type
+		if strings.Contains(opts, "string") {
+			// The field is encoded as a JSON string.
+			typ = reflect.TypeFor[string]()
+		}
+		if name == key {
+			return typ
+		}
+		if fold == nil && strings.EqualFold(name, key) {
+			fold = typ
+		}
+	}
+	return fold
+}
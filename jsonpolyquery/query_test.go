@@ -0,0 +1,206 @@
+package jsonpolyquery
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+type Animal interface {
+	Type() string
+}
+
+type Dog struct {
+	Name  string `json:"name"`
+	Breed string `json:"breed,omitempty"`
+}
+
+func (Dog) Type() string { return "dog" }
+
+type Cat struct {
+	Name   string    `json:"name"`
+	Lives  int       `json:"lives"`
+	Weight float64   `json:"weight"`
+	Indoor bool      `json:"indoor"`
+	Toys   []string  `json:"toys"`
+	Scores []int     `json:"scores,omitempty"`
+	Born   time.Time `json:"born"`
+	Chip   int       `json:"chip,string"`
+}
+
+func (Cat) Type() string { return "cat" }
+
+// Kennel contains nested objects, it can not be encoded.
+type Kennel struct {
+	Dogs []Dog `json:"dogs"`
+}
+
+func (Kennel) Type() string { return "kennel" }
+
+type AnimalHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AnimalHelper) Get() Animal {
+	switch h.Type {
+	case "dog":
+		return Dog{}
+	case "cat":
+		return &Cat{}
+	case "kennel":
+		return Kennel{}
+	}
+	return nil
+}
+
+func (h *AnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		want Animal
+	}{{
+		name: "dog",
+		have: "type=dog&name=Fido&breed=Pug",
+		want: Dog{Name: "Fido", Breed: "Pug"},
+	}, {
+		name: "first_value",
+		have: "type=dog&name=Fido&name=Rex",
+		want: Dog{Name: "Fido"},
+	}, {
+		name: "case_insensitive",
+		have: "type=dog&Name=Fido",
+		want: Dog{Name: "Fido"},
+	}, {
+		name: "typed_fields",
+		have: "type=cat&name=Tom&lives=9&weight=4.5&indoor=true&toys=ball&toys=mouse&scores=1&scores=-2&born=2020-01-02T00:00:00Z&chip=42",
+		want: &Cat{
+			Name:   "Tom",
+			Lives:  9,
+			Weight: 4.5,
+			Indoor: true,
+			Toys:   []string{"ball", "mouse"},
+			Scores: []int{1, -2},
+			Born:   time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+			Chip:   42,
+		},
+	}, {
+		name: "numeric_string",
+		have: "type=dog&name=123",
+		want: Dog{Name: "123"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tc.have)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode[Animal, *AnimalHelper](q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDecode_error(t *testing.T) {
+	t.Run("invalid_number", func(t *testing.T) {
+		_, err := Decode[Animal, *AnimalHelper](url.Values{"type": {"cat"}, "lives": {"nine"}})
+		if err == nil || !strings.Contains(err.Error(), "lives") {
+			t.Fatalf("want error for lives, got %v", err)
+		}
+	})
+
+	t.Run("unknown_type", func(t *testing.T) {
+		_, err := Decode[Animal, *AnimalHelper](url.Values{"type": {"dolphin"}})
+		if !errors.Is(err, jsonpoly.ErrUnknownType) {
+			t.Fatalf("want %v, got %v", jsonpoly.ErrUnknownType, err)
+		}
+	})
+
+	t.Run("missing_type", func(t *testing.T) {
+		_, err := Decode[Animal, *AnimalHelper](url.Values{"name": {"Fido"}})
+		if !errors.Is(err, jsonpoly.ErrMissingType) {
+			t.Fatalf("want %v, got %v", jsonpoly.ErrMissingType, err)
+		}
+	})
+
+	t.Run("case_duplicate", func(t *testing.T) {
+		_, err := Decode[Animal, *AnimalHelper](url.Values{"type": {"dog"}, "Type": {"cat"}, "name": {"Fido"}})
+		if !errors.Is(err, jsonpoly.ErrDuplicateKey) {
+			t.Fatalf("want %v, got %v", jsonpoly.ErrDuplicateKey, err)
+		}
+	})
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want url.Values
+	}{{
+		name: "dog",
+		have: Dog{Name: "Fido"},
+		want: url.Values{"type": {"dog"}, "name": {"Fido"}},
+	}, {
+		name: "cat",
+		have: &Cat{
+			Name:   "Tom",
+			Lives:  9,
+			Weight: 4.5,
+			Toys:   []string{"ball", "mouse"},
+			Born:   time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+			Chip:   42,
+		},
+		want: url.Values{
+			"type":   {"cat"},
+			"name":   {"Tom"},
+			"lives":  {"9"},
+			"weight": {"4.5"},
+			"indoor": {"false"},
+			"toys":   {"ball", "mouse"},
+			"born":   {"2020-01-02T00:00:00Z"},
+			"chip":   {"42"},
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Encode[Animal, *AnimalHelper](tc.have)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+
+			// The encoded values decode into the same value.
+			v, err := Decode[Animal, *AnimalHelper](got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, tc.have) {
+				t.Fatalf("want %#v, got %#v", tc.have, v)
+			}
+		})
+	}
+
+	t.Run("not_flat", func(t *testing.T) {
+		_, err := Encode[Animal, *AnimalHelper](Kennel{Dogs: []Dog{{Name: "Fido"}}})
+		if !errors.Is(err, ErrNotFlat) {
+			t.Fatalf("want %v, got %v", ErrNotFlat, err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Package jsonpolytest provides utilities for testing types marshalled using
+// jsonpoly.
+package jsonpolytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lovromazgon/jsonpoly"
+)
+
+// UpdateEnv is the environment variable that makes RoundTripGolden write the
+// golden files instead of comparing them, if it is set to a non-empty value.
+const UpdateEnv = "JSONPOLY_UPDATE_GOLDEN"
+
+// RoundTripGolden marshals the value using Container[V, H] and compares the
+// output to the JSON in the golden file. The golden file can be formatted in
+// any way, only the key order and values need to match. The output is then
+// unmarshalled and compared to the original value using reflect.DeepEqual.
+//
+// If the environment variable JSONPOLY_UPDATE_GOLDEN is set, the golden file
+// is written with the indented output instead.
+func RoundTripGolden[V any, H jsonpoly.Helper[V]](t testing.TB, value V, goldenPath string) {
+	t.Helper()
+
+	got, err := json.Marshal(jsonpoly.New[V, H](value))
+	if err != nil {
+		t.Fatalf("failed to marshal value: %v", err)
+	}
+
+	if os.Getenv(UpdateEnv) != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, got, "", "  "); err != nil {
+			t.Fatalf("failed to indent output: %v", err)
+		}
+		buf.WriteByte('\n')
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			t.Fatalf("failed to create directory for golden file: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+	}
+
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set %s=1 to create it): %v", UpdateEnv, err)
+	}
+	var want bytes.Buffer
+	if err := json.Compact(&want, golden); err != nil {
+		t.Fatalf("invalid JSON in golden file %s: %v", goldenPath, err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("output does not match golden file %s\nwant: %s\ngot:  %s", goldenPath, want.Bytes(), got)
+	}
+
+	var c jsonpoly.Container[V, H]
+	if err := json.Unmarshal(got, &c); err != nil {
+		t.Fatalf("failed to unmarshal output: %v", err)
+	}
+	if !reflect.DeepEqual(c.Value, value) {
+		t.Fatalf("round trip changed the value\nwant: %#v\ngot:  %#v", value, c.Value)
+	}
+}
@@ -0,0 +1,107 @@
+package jsonpolytest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type Animal interface {
+	Type() string
+}
+
+type Dog struct {
+	Name  string `json:"name"`
+	Breed string `json:"breed"`
+}
+
+type Cat struct {
+	Name  string   `json:"name"`
+	Lives int      `json:"lives"`
+	Toys  []string `json:"toys,omitempty"`
+}
+
+func (Dog) Type() string { return "dog" }
+func (Cat) Type() string { return "cat" }
+
+type AnimalHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AnimalHelper) Get() Animal {
+	switch h.Type {
+	case "dog":
+		return Dog{}
+	case "cat":
+		return &Cat{}
+	}
+	return nil
+}
+
+func (h *AnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestRoundTripGolden(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+	}{{
+		name: "dog",
+		have: Dog{Name: "Fido", Breed: "Golden Retriever"},
+	}, {
+		name: "cat",
+		have: &Cat{Name: "Tom", Lives: 9, Toys: []string{"mouse", "ball"}},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			RoundTripGolden[Animal, *AnimalHelper](t, tc.have, filepath.Join("testdata", tc.name+".json"))
+		})
+	}
+}
+
+func TestRoundTripGolden_update(t *testing.T) {
+	t.Setenv(UpdateEnv, "1")
+	path := filepath.Join(t.TempDir(), "dog.json")
+
+	RoundTripGolden[Animal, *AnimalHelper](t, Dog{Name: "Fido"}, path)
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"type\": \"dog\",\n  \"name\": \"Fido\",\n  \"breed\": \"\"\n}\n"
+	if string(got) != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestRoundTripGolden_mismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dog.json")
+	if err := os.WriteFile(path, []byte(`{"type":"dog","name":"Rex","breed":""}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &fakeTB{TB: t}
+	func() {
+		defer func() { _ = recover() }()
+		RoundTripGolden[Animal, *AnimalHelper](ft, Dog{Name: "Fido"}, path)
+	}()
+	if !ft.failed {
+		t.Fatal("expected mismatch to fail the test")
+	}
+}
+
+// fakeTB records failures instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Fatalf(string, ...any) {
+	f.failed = true
+	panic("fatal")
+}
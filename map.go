@@ -0,0 +1,32 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// UnmarshalMap works the same as UnmarshalJSON, but takes a JSON object that
+// was already split into its fields, e.g. by unmarshalling a document into a
+// map[string]json.RawMessage. The fields are joined into an object without
+// decoding or re-encoding the values, in the order of their keys. A nil map is
+// treated the same as the JSON input null.
+func (c *Container[V, H]) UnmarshalMap(m map[string]json.RawMessage) error {
+	if m == nil {
+		return c.UnmarshalJSON([]byte("null"))
+	}
+
+	fields := make([]objectField, 0, len(m))
+	for k, v := range m {
+		fields = append(fields, objectField{key: k, value: v})
+	}
+	slices.SortFunc(fields, func(a, b objectField) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	b, err := writeObject(fields)
+	if err != nil {
+		return err
+	}
+	return c.UnmarshalJSON(b)
+}
@@ -0,0 +1,84 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestContainer_UnmarshalMap(t *testing.T) {
+	t.Run("dog", func(t *testing.T) {
+		m := map[string]json.RawMessage{
+			"type":  json.RawMessage(`"dog"`),
+			"name":  json.RawMessage(`"Fido"`),
+			"breed": json.RawMessage(`"Pug"`),
+		}
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalMap(m); err != nil {
+			t.Fatal(err)
+		}
+		want := Dog{XName: "Fido", Breed: "Pug"}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		// The document is partially parsed, the animal is decoded from the
+		// remaining fields.
+		var m map[string]json.RawMessage
+		err := json.Unmarshal([]byte(`{"id":7,"type":"cat","name":"Tom","color":"grey"}`), &m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var id int
+		if err := json.Unmarshal(m["id"], &id); err != nil {
+			t.Fatal(err)
+		}
+		delete(m, "id")
+
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalMap(m); err != nil {
+			t.Fatal(err)
+		}
+		want := Cat{XName: "Tom", Color: "grey"}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		m := map[string]json.RawMessage{
+			"type": json.RawMessage(`"dog"`),
+			"data": json.RawMessage(`{"name":"Fido"}`),
+		}
+		var c Container[Animal, *EnvelopeAnimalHelper]
+		if err := c.UnmarshalMap(m); err != nil {
+			t.Fatal(err)
+		}
+		want := Dog{XName: "Fido"}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("invalid_value", func(t *testing.T) {
+		m := map[string]json.RawMessage{
+			"type": json.RawMessage(`"dog"`),
+			"name": json.RawMessage(`{`),
+		}
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalMap(m); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		c := New[Animal, *AnimalContainerHelper](Dog{XName: "Fido"})
+		if err := c.UnmarshalMap(nil); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != nil {
+			t.Fatalf("want nil, got %v", c.Value)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package jsonpoly
+
+// Typer is implemented by values that describe their own type.
+type Typer interface {
+	Type() string
+}
+
+// RegistryProvider provides the registry used by MethodHelper. Registry is
+// called on the zero value of the provider, so the provider is usually an
+// empty struct returning a package-level registry.
+type RegistryProvider[V any] interface {
+	Registry() *Registry[V]
+}
+
+// MethodHelper is a Helper for values that implement Typer, which removes the
+// need to write a helper when the values already describe their type. The
+// discriminator is stored under the key "type". Set uses the Type method of
+// the value, while Get looks up the value in the registry provided by R, so
+// all types need to be registered before they are unmarshalled (e.g. in init
+// using Registry.RegisterAll with V.Type as the key).
+type MethodHelper[V Typer, R RegistryProvider[V]] struct {
+	Type string `json:"type"`
+}
+
+func (h *MethodHelper[V, R]) Get() V {
+	var r R
+	v, _ := r.Registry().Lookup(h.Type)
+	return v
+}
+
+func (h *MethodHelper[V, R]) Set(v V) {
+	h.Type = v.Type()
+}
@@ -0,0 +1,70 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var animalTypes, dogTypes Registry[Animal]
+
+func init() {
+	animalTypes.RegisterAll(Animal.Type, Dog{}, Cat{}, Bird{})
+	dogTypes.RegisterAll(Animal.Type, Dog{})
+}
+
+// AnimalTypes provides the registry of all animals to MethodHelper.
+type AnimalTypes struct{}
+
+func (AnimalTypes) Registry() *Registry[Animal] {
+	return &animalTypes
+}
+
+// DogTypes provides a registry containing only dogs to MethodHelper.
+type DogTypes struct{}
+
+func (DogTypes) Registry() *Registry[Animal] {
+	return &dogTypes
+}
+
+func TestMethodHelper(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "dog",
+		have: Dog{XName: "Fido", Breed: "Pug"},
+		want: `{"type":"dog","name":"Fido","breed":"Pug"}`,
+	}, {
+		name: "cat",
+		have: Cat{XName: "Whiskers", Owner: "Alice", Color: "White"},
+		want: `{"type":"cat","name":"Whiskers","owner":"Alice","color":"White"}`,
+	}, {
+		name: "bird",
+		have: Bird{XType: "bird", XName: "Tweety"},
+		want: `{"type":"bird","name":"Tweety"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Animal, *MethodHelper[Animal, AnimalTypes]](t, tc.have, tc.want)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		var c Container[Animal, *MethodHelper[Animal, AnimalTypes]]
+		err := json.Unmarshal([]byte(`{"type":"dolphin","name":"Cooper"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+	})
+
+	t.Run("separate_registry", func(t *testing.T) {
+		var c Container[Animal, *MethodHelper[Animal, DogTypes]]
+		err := json.Unmarshal([]byte(`{"type":"cat","name":"Whiskers"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+	})
+}
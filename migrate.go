@@ -0,0 +1,41 @@
+package jsonpoly
+
+import "fmt"
+
+// Migrator is an optional interface a value can implement if it represents an
+// older version of a type. After a value is unmarshalled, Migrate is called
+// repeatedly until the returned value does not implement Migrator anymore,
+// which allows migrating a value through multiple versions. The current
+// version of a type must therefore not implement Migrator. If the value is
+// still a Migrator after maxMigrations steps (e.g. because Migrate returns
+// the value itself), an error wrapping ErrTooManyMigrations is returned.
+//
+// Migrator is typically used together with a helper that reads a version key
+// next to the discriminator and returns the type for that version in Get.
+type Migrator[V any] interface {
+	Migrate() (V, error)
+}
+
+// maxMigrations limits the number of migration steps of a single value, a
+// longer chain is most likely a Migrate method that never reaches the
+// current version.
+const maxMigrations = 100
+
+// migrate migrates v to the current version if it implements Migrator.
+func migrate[V any](v V) (V, error) {
+	for i := 0; i < maxMigrations; i++ {
+		m, ok := any(v).(Migrator[V])
+		if !ok {
+			return v, nil
+		}
+		var err error
+		v, err = m.Migrate()
+		if err != nil {
+			return v, err
+		}
+	}
+	if _, ok := any(v).(Migrator[V]); ok {
+		return v, fmt.Errorf("%w: %T is still not migrated after %d steps", ErrTooManyMigrations, v, maxMigrations)
+	}
+	return v, nil
+}
@@ -0,0 +1,137 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+var errNoName = errors.New("name is required")
+
+// DogV1 is the first version of Dog, it stored the name in a differently named
+// field.
+type DogV1 struct {
+	DogName string `json:"dog_name"`
+}
+
+func (DogV1) Type() string {
+	return "dog"
+}
+func (d DogV1) Name() string {
+	return d.DogName
+}
+func (d DogV1) Migrate() (Animal, error) {
+	if d.DogName == "" {
+		return nil, errNoName
+	}
+	return Dog{XName: d.DogName}, nil
+}
+
+// MigratingAnimalHelper returns the type based on the type and version.
+type MigratingAnimalHelper struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+func (h *MigratingAnimalHelper) Get() Animal {
+	switch h.Type {
+	case "dog":
+		if h.Version < 2 {
+			return DogV1{}
+		}
+		return Dog{}
+	case "cat":
+		return Cat{}
+	}
+	return nil
+}
+
+func (h *MigratingAnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+	h.Version = 2
+}
+
+func TestContainer_migrate(t *testing.T) {
+	var c Container[Animal, *MigratingAnimalHelper]
+	err := json.Unmarshal([]byte(`{"type":"dog","version":1,"dog_name":"Fido"}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Dog{XName: "Fido"}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	// The migrated value is marshalled in the current version.
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"type":"dog","version":2,"name":"Fido","breed":""}`; string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":"dog","version":1}`), &c)
+	if !errors.Is(err, errNoName) {
+		t.Fatalf("want %v, got %v", errNoName, err)
+	}
+}
+
+func TestDecodeSlice_migrate(t *testing.T) {
+	raw := `[
+		{"type":"dog","version":1,"dog_name":"Fido"},
+		{"type":"dog","version":2,"name":"Rex","breed":"Pug"},
+		{"type":"cat","name":"Tom"},
+		{"type":"dog","dog_name":"Max"}
+	]`
+
+	var got []Animal
+	if err := DecodeSlice[Animal, *MigratingAnimalHelper]([]byte(raw), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Animal{
+		Dog{XName: "Fido"},
+		Dog{XName: "Rex", Breed: "Pug"},
+		Cat{XName: "Tom"},
+		Dog{XName: "Max"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+// StuckDog migrates to itself by mistake.
+type StuckDog struct {
+	XName string `json:"name"`
+}
+
+func (StuckDog) Type() string {
+	return "dog"
+}
+func (d StuckDog) Name() string {
+	return d.XName
+}
+func (d StuckDog) Migrate() (Animal, error) {
+	return d, nil
+}
+
+type StuckAnimalHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *StuckAnimalHelper) Get() Animal {
+	return StuckDog{}
+}
+
+func (h *StuckAnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestContainer_migrateLoop(t *testing.T) {
+	var c Container[Animal, *StuckAnimalHelper]
+	err := json.Unmarshal([]byte(`{"type":"dog","name":"Fido"}`), &c)
+	if !errors.Is(err, ErrTooManyMigrations) {
+		t.Fatalf("want %v, got %v", ErrTooManyMigrations, err)
+	}
+}
@@ -0,0 +1,427 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errInvalidJSON = errors.New("invalid JSON")
+
+// objectField is a single key-value pair of a JSON object.
+type objectField struct {
+	key   string
+	value json.RawMessage
+}
+
+// parseObject splits a JSON object into its top-level fields, preserving the
+// order in which they appear. The values reference the input slice.
+func parseObject(o []byte) ([]objectField, error) {
+	var fields []objectField
+	err := scanObject(o, func(key, value []byte) error {
+		k, err := unquoteKey(key)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, objectField{key: k, value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// writeObject writes the fields into a JSON object.
+func writeObject(fields []objectField) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(f.value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// normalizeKeys replaces the top-level keys of a JSON object with the keys
+// returned by fn. The values are copied as they are.
+func normalizeKeys(o []byte, fn func(string) string) ([]byte, error) {
+	fields, err := parseObject(o)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fields {
+		fields[i].key = fn(fields[i].key)
+	}
+	return writeObject(fields)
+}
+
+// sortKeys sorts the top-level fields of a JSON object by key. The values
+// are copied as they are.
+func sortKeys(o []byte) ([]byte, error) {
+	fields, err := parseObject(o)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(fields, func(a, b objectField) int {
+		return strings.Compare(a.key, b.key)
+	})
+	return writeObject(fields)
+}
+
+// checkDepth returns ErrMaxDepthExceeded if objects and arrays in b are
+// nested deeper than maxDepth. The top-level value has depth 1. Mismatched
+// or unbalanced brackets are reported as invalid JSON.
+func checkDepth(b []byte, maxDepth int) error {
+	// The closing brackets of the open objects and arrays, the stack never
+	// grows beyond maxDepth.
+	var stack []byte
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			end, err := skipString(b, i)
+			if err != nil {
+				return err
+			}
+			i = end - 1
+		case '{', '[':
+			if len(stack) == maxDepth {
+				return fmt.Errorf("%w: offset %d", ErrMaxDepthExceeded, i)
+			}
+			if b[i] == '{' {
+				stack = append(stack, '}')
+			} else {
+				stack = append(stack, ']')
+			}
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != b[i] {
+				return invalidJSONError(i)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		return invalidJSONError(len(b))
+	}
+	return nil
+}
+
+// checkAllDuplicateKeys returns ErrDuplicateKey if any object in the JSON
+// value b, at any depth, contains the same key more than once. Keys are
+// compared case-insensitively. The error contains the path of the object,
+// which is path for b itself. The input is scanned in a single pass using an
+// explicit stack, so deeply nested input does not exhaust the call stack.
+func checkAllDuplicateKeys(b []byte, path string) error {
+	// Each frame is an open object or array, name is the path segment of
+	// the frame relative to its parent (e.g. ".owner" or "[1]").
+	type frame struct {
+		object bool
+		name   string
+		keys   map[string]bool
+		key    string // Last key in an object.
+		n      int    // Index of the current element in an array.
+	}
+	var stack []frame
+	framePath := func() string {
+		var sb strings.Builder
+		sb.WriteString(path)
+		for _, f := range stack {
+			sb.WriteString(f.name)
+		}
+		return sb.String()
+	}
+	// childName returns the path segment of a value nested in the top frame.
+	childName := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			return "." + top.key
+		}
+		return "[" + strconv.Itoa(top.n) + "]"
+	}
+
+	expectKey := false
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			end, err := skipString(b, i)
+			if err != nil {
+				return err
+			}
+			if expectKey {
+				k, err := unquoteKey(b[i:end])
+				if err != nil {
+					return err
+				}
+				top := &stack[len(stack)-1]
+				lower := strings.ToLower(k)
+				if top.keys[lower] {
+					return fmt.Errorf("%w %q at %s", ErrDuplicateKey, k, framePath())
+				}
+				top.keys[lower] = true
+				top.key = k
+				expectKey = false
+			}
+			i = end - 1
+		case '{':
+			stack = append(stack, frame{object: true, name: childName(), keys: make(map[string]bool)})
+			expectKey = true
+		case '[':
+			stack = append(stack, frame{name: childName()})
+		case ',':
+			if len(stack) == 0 {
+				return invalidJSONError(i)
+			}
+			if top := &stack[len(stack)-1]; top.object {
+				expectKey = true
+			} else {
+				top.n++
+			}
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1].object != (b[i] == '}') {
+				return invalidJSONError(i)
+			}
+			stack = stack[:len(stack)-1]
+			expectKey = false
+		}
+	}
+	if len(stack) > 0 {
+		return invalidJSONError(len(b))
+	}
+	return nil
+}
+
+// selectFields returns a JSON object containing only the top-level fields of
+// o with the given keys. Keys are matched case-insensitively, the same as
+// when unmarshalling into a struct. The raw bytes of the fields are copied
+// as they are, without decoding them.
+func selectFields(o []byte, keys []string) ([]byte, error) {
+	return filterFields(o, keys, true)
+}
+
+// omitFields is the opposite of selectFields, it returns a JSON object
+// containing all top-level fields of o except the ones with the given keys.
+func omitFields(o []byte, keys []string) ([]byte, error) {
+	return filterFields(o, keys, false)
+}
+
+// filterFields returns a JSON object containing the top-level fields of o
+// for which matching any of the keys equals keep.
+func filterFields(o []byte, keys []string, keep bool) ([]byte, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '{')
+	err := scanObject(o, func(key, value []byte) error {
+		if containsKeyFold(keys, key) != keep {
+			return nil
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '}'), nil
+}
+
+// findField returns the raw value of the top-level field in o with the given
+// key. Keys are matched case-insensitively, if the key appears more than once
+// the last value is returned, the same as when unmarshalling into a struct.
+func findField(o []byte, key string) (value []byte, ok bool, err error) {
+	keys := []string{key}
+	err = scanObject(o, func(k, v []byte) error {
+		if containsKeyFold(keys, k) {
+			value, ok = v, true
+		}
+		return nil
+	})
+	return value, ok, err
+}
+
+// containsKeyFold reports whether the quoted JSON key matches any of the keys
+// case-insensitively.
+func containsKeyFold(keys []string, quoted []byte) bool {
+	raw := quoted[1 : len(quoted)-1]
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		// The key contains escape sequences, we need to unquote it.
+		k, err := unquoteKey(quoted)
+		if err != nil {
+			return false
+		}
+		raw = []byte(k)
+	}
+	for _, k := range keys {
+		if bytes.EqualFold([]byte(k), raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// unquoteKey returns the string represented by the quoted JSON key.
+func unquoteKey(quoted []byte) (string, error) {
+	raw := quoted[1 : len(quoted)-1]
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw), nil
+	}
+	var k string
+	if err := json.Unmarshal(quoted, &k); err != nil {
+		return "", err
+	}
+	return k, nil
+}
+
+// scanObject calls fn for each top-level field of the JSON object in o, with
+// the quoted key and the raw value. The values are skipped without decoding
+// them, the scanner only validates the structure of the object, not the
+// contents of strings, numbers and literals.
+func scanObject(o []byte, fn func(key, value []byte) error) error {
+	i := skipSpace(o, 0)
+	if i >= len(o) || o[i] != '{' {
+		return ErrNotJSONObject
+	}
+
+	i = skipSpace(o, i+1)
+	if i < len(o) && o[i] == '}' {
+		return checkEnd(o, i+1)
+	}
+
+	for {
+		if i >= len(o) || o[i] != '"' {
+			return invalidJSONError(i)
+		}
+		end, err := skipString(o, i)
+		if err != nil {
+			return err
+		}
+		key := o[i:end]
+
+		i = skipSpace(o, end)
+		if i >= len(o) || o[i] != ':' {
+			return invalidJSONError(i)
+		}
+
+		i = skipSpace(o, i+1)
+		end, err = skipValue(o, i)
+		if err != nil {
+			return err
+		}
+		if err := fn(key, o[i:end]); err != nil {
+			return err
+		}
+
+		i = skipSpace(o, end)
+		if i >= len(o) {
+			return invalidJSONError(i)
+		}
+		switch o[i] {
+		case ',':
+			i = skipSpace(o, i+1)
+		case '}':
+			return checkEnd(o, i+1)
+		default:
+			return invalidJSONError(i)
+		}
+	}
+}
+
+// skipValue returns the index right after the JSON value starting at i.
+func skipValue(b []byte, i int) (int, error) {
+	if i >= len(b) {
+		return 0, invalidJSONError(i)
+	}
+
+	switch b[i] {
+	case '"':
+		return skipString(b, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(b); j++ {
+			switch b[j] {
+			case '"':
+				end, err := skipString(b, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, invalidJSONError(len(b))
+	default:
+		// Number or literal, it ends at the next delimiter.
+		j := i
+		for j < len(b) && !isDelimiter(b[j]) {
+			j++
+		}
+		if j == i {
+			return 0, invalidJSONError(i)
+		}
+		return j, nil
+	}
+}
+
+// skipString returns the index right after the JSON string starting at i.
+func skipString(b []byte, i int) (int, error) {
+	for j := i + 1; j < len(b); j++ {
+		switch b[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, invalidJSONError(len(b))
+}
+
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && isSpace(b[i]) {
+		i++
+	}
+	return i
+}
+
+// checkEnd returns an error if anything but whitespace follows index i.
+func checkEnd(b []byte, i int) error {
+	if i = skipSpace(b, i); i < len(b) {
+		return invalidJSONError(i)
+	}
+	return nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDelimiter(c byte) bool {
+	return isSpace(c) || c == ',' || c == '}' || c == ']' || c == ':'
+}
+
+func invalidJSONError(offset int) error {
+	return fmt.Errorf("%w: unexpected input at offset %d", errInvalidJSON, offset)
+}
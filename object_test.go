@@ -0,0 +1,123 @@
+package jsonpoly
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectFields(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		keys []string
+		want string
+	}{
+		{
+			name: "simple",
+			have: `{"type":"dog","name":"Fido"}`,
+			keys: []string{"type"},
+			want: `{"type":"dog"}`,
+		},
+		{
+			name: "case_insensitive",
+			have: `{"TYPE":"dog","name":"Fido"}`,
+			keys: []string{"type"},
+			want: `{"TYPE":"dog"}`,
+		},
+		{
+			name: "escaped_key",
+			have: `{"\u0074ype":"dog","name":"Fido"}`,
+			keys: []string{"type"},
+			want: `{"\u0074ype":"dog"}`,
+		},
+		{
+			name: "nested",
+			have: ` { "data" : {"type":"cat","list":[1,"}",{"a":null}]} , "type" : "dog" } `,
+			keys: []string{"type"},
+			want: `{"type":"dog"}`,
+		},
+		{
+			name: "empty",
+			have: `{}`,
+			keys: []string{"type"},
+			want: `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := selectFields([]byte(tc.have), tc.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, string(got))
+			}
+		})
+	}
+}
+
+func TestOmitFields(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		keys []string
+		want string
+	}{
+		{
+			name: "simple",
+			have: `{"type":"dog","name":"Fido"}`,
+			keys: []string{"type"},
+			want: `{"name":"Fido"}`,
+		},
+		{
+			name: "case_insensitive",
+			have: `{"TYPE":"dog","name":"Fido","Type":"cat"}`,
+			keys: []string{"type"},
+			want: `{"name":"Fido"}`,
+		},
+		{
+			name: "nested",
+			have: ` { "data" : {"type":"cat"} , "type" : "dog" } `,
+			keys: []string{"type"},
+			want: `{"data":{"type":"cat"}}`,
+		},
+		{
+			name: "all",
+			have: `{"type":"dog","version":1}`,
+			keys: []string{"type", "version"},
+			want: `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := omitFields([]byte(tc.have), tc.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, string(got))
+			}
+		})
+	}
+}
+
+func TestScanObject_invalid(t *testing.T) {
+	for _, have := range []string{
+		`{`,
+		`{"type"}`,
+		`{"type":}`,
+		`{"type":"dog",}`,
+		`{"type":"dog"`,
+		`{"type":"dog}`,
+		`{"type":{"a":1}`,
+		`{"type":"dog"} x`,
+		`{type:"dog"}`,
+	} {
+		err := scanObject([]byte(have), func(_, _ []byte) error { return nil })
+		if !errors.Is(err, errInvalidJSON) {
+			t.Fatalf("%s: want %v, got %v", have, errInvalidJSON, err)
+		}
+	}
+}
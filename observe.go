@@ -0,0 +1,24 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+)
+
+// Operations reported to Options.Observer.
+const (
+	OpMarshal   = "marshal"
+	OpUnmarshal = "unmarshal"
+)
+
+// observedType returns the discriminator reported to Options.Observer. If the
+// helper does not have a single string field, its JSON object is returned.
+func observedType(helper any) string {
+	if typ, err := discriminatorValue(helper); err == nil {
+		return typ
+	}
+	b, err := json.Marshal(helper)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
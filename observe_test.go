@@ -0,0 +1,111 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type observation struct {
+	op, typ string
+	err     error
+}
+
+// observations collects the calls of the observer of ObservedAnimalHelper.
+var observations []observation
+
+type ObservedAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *ObservedAnimalHelper) Options() Options {
+	return Options{
+		Observer: func(op, typ string, err error) {
+			observations = append(observations, observation{op: op, typ: typ, err: err})
+		},
+	}
+}
+
+// ObservedShapeHelper has two fields, the whole helper is reported.
+type ObservedShapeHelper struct {
+	ObservedAnimalHelper
+	Legs int `json:"legs"`
+}
+
+func TestOptions_Observer(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fn      func() error
+		want    observation
+		wantErr bool
+	}{{
+		name: "marshal",
+		fn: func() error {
+			_, err := json.Marshal(New[Animal, *ObservedAnimalHelper](Dog{XName: "Fido"}))
+			return err
+		},
+		want: observation{op: OpMarshal, typ: "dog"},
+	}, {
+		name: "unmarshal",
+		fn: func() error {
+			var c Container[Animal, *ObservedAnimalHelper]
+			return json.Unmarshal([]byte(`{"type":"cat","name":"Tom"}`), &c)
+		},
+		want: observation{op: OpUnmarshal, typ: "cat"},
+	}, {
+		name: "unmarshal_invalid_value",
+		fn: func() error {
+			var c Container[Animal, *ObservedAnimalHelper]
+			return json.Unmarshal([]byte(`{"type":"dog","name":1}`), &c)
+		},
+		want:    observation{op: OpUnmarshal, typ: "dog"},
+		wantErr: true,
+	}, {
+		name: "unmarshal_not_object",
+		fn: func() error {
+			var c Container[Animal, *ObservedAnimalHelper]
+			return c.UnmarshalJSON([]byte(`[]`))
+		},
+		want:    observation{op: OpUnmarshal, typ: ""},
+		wantErr: true,
+	}, {
+		name: "multiple_fields",
+		fn: func() error {
+			var c Container[Animal, *ObservedShapeHelper]
+			return json.Unmarshal([]byte(`{"type":"dog","legs":4}`), &c)
+		},
+		want: observation{op: OpUnmarshal, typ: `{"type":"dog","legs":4}`},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observations = nil
+			err := tc.fn()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if len(observations) != 1 {
+				t.Fatalf("want 1 observation, got %v", observations)
+			}
+			got := observations[0]
+			if got.op != tc.want.op || got.typ != tc.want.typ {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+			// The observer receives the returned error, encoding/json might
+			// wrap it.
+			if (got.err != nil) != tc.wantErr || !errors.Is(err, got.err) {
+				t.Fatalf("want observed error %v, got %v", err, got.err)
+			}
+		})
+	}
+
+	t.Run("nil_value", func(t *testing.T) {
+		observations = nil
+		if _, err := json.Marshal(Container[Animal, *ObservedAnimalHelper]{}); err != nil {
+			t.Fatal(err)
+		}
+		if len(observations) != 0 {
+			t.Fatalf("want no observations, got %v", observations)
+		}
+	})
+}
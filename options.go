@@ -0,0 +1,245 @@
+package jsonpoly
+
+// Options control optional behavior of a Container. A Helper can provide
+// options by implementing OptionsProvider, the zero value represents the
+// default behavior.
+type Options struct {
+	// RejectDuplicateKeys makes the container return ErrDuplicateKey when
+	// unmarshalling a JSON object that contains the same top-level key more
+	// than once. By default the last value is used, same as in encoding/json.
+	// Keys are compared case-insensitively, since encoding/json matches them
+	// to struct fields the same way.
+	RejectDuplicateKeys bool
+
+	// RejectAnyDuplicateKeys works like RejectDuplicateKeys, but checks all
+	// objects in the input, including objects nested in the value and in
+	// arrays. Duplicate keys usually indicate a malformed or malicious
+	// producer, since different parsers resolve them differently. The error
+	// contains the path of the object with the duplicate key (e.g.
+	// $.owner.pets[1]).
+	RejectAnyDuplicateKeys bool
+
+	// Union makes the container treat the value as a tagged union instead of
+	// an interface. The value needs to be a struct with exported pointer
+	// fields, one for each variant. Helper.Get should return a union with
+	// exactly one field set to a newly allocated variant, the JSON object is
+	// unmarshalled into that variant. When marshalling, only the non-nil field
+	// of the union is marshalled, and Helper.Set receives the whole union.
+	Union bool
+
+	// IgnoreTypeOnUnmarshal makes the container skip unmarshalling the helper
+	// from the JSON object. Helper.Get is called on a zero helper, which is
+	// useful when the type is implied by the context and the discriminator is
+	// optional in the input. The discriminator is still emitted when
+	// marshalling.
+	IgnoreTypeOnUnmarshal bool
+
+	// UseNumber makes the container unmarshal numbers in the value into
+	// json.Number instead of float64 when the destination is an interface
+	// (e.g. a field of type any or a map[string]any). This prevents losing
+	// precision for big integers.
+	UseNumber bool
+
+	// Envelope makes the container store the value in a separate field of the
+	// JSON object instead of merging its fields with the discriminator, e.g.
+	// {"type":"dog","data":{"name":"Fido"}}. The key of the field is "data",
+	// unless the helper implements EnvelopeHelper. The value does not need to
+	// marshal into a JSON object in this mode, so values can also be slices
+	// or maps (e.g. {"type":"list","data":[...]}).
+	Envelope bool
+
+	// AutoEnvelope makes the container choose the mode for each value when
+	// marshalling: values that marshal into a JSON object are merged with
+	// the discriminator as usual, while other values (e.g. slices, strings
+	// or numbers) are stored in an envelope, the same as with Envelope.
+	// Values whose object contains the key of the envelope are enveloped as
+	// well, so the key works as a marker. When unmarshalling, the object is
+	// treated as an envelope if it contains the key, the value then must not
+	// have a field with the same key in flat mode.
+	AutoEnvelope bool
+
+	// JSONC makes the container accept JSON with comments and trailing
+	// commas, which is common in human-edited configuration files. Comments
+	// and trailing commas are stripped before the input is unmarshalled.
+	// The input stored because of KeepRaw or OnUnknownCapture is the
+	// stripped input, so it can be marshalled as valid JSON. Note that
+	// encoding/json validates the input before calling UnmarshalJSON, so
+	// JSONC input needs to be passed to Container.UnmarshalJSON or
+	// Unmarshal directly, not to json.Unmarshal.
+	JSONC bool
+
+	// Untyped makes the container treat the whole JSON object as the value,
+	// without a discriminator. The helper is neither unmarshalled nor
+	// marshalled, Helper.Get is called on a zero helper and should return a
+	// fixed type. This allows using Container uniformly for types that are
+	// not polymorphic (yet), and adding a discriminator later.
+	Untyped bool
+
+	// Codec is used to marshal and unmarshal the value instead of
+	// encoding/json. The helper is still marshalled using encoding/json and
+	// merged with the value, so Codec needs to marshal the value into a JSON
+	// object (unless Envelope is enabled). When unmarshalling, Codec receives
+	// the whole JSON object including the discriminator keys. UseNumber has no
+	// effect if Codec is set.
+	Codec Codec
+
+	// SortKeys makes the container sort the top-level fields of the value by
+	// key when marshalling, which produces stable output that is easier to
+	// diff (e.g. for configuration stored in git). The fields of the helper
+	// still come first in the order produced by the helper, so the
+	// discriminator stays at the start of the object. Nested objects are not
+	// sorted, they keep the order produced by encoding/json (struct field
+	// order for structs and sorted keys for maps).
+	SortKeys bool
+
+	// MaxDepth limits how deeply objects and arrays can be nested in the
+	// input, the object of the container itself has depth 1. If the input
+	// is nested deeper, ErrMaxDepthExceeded is returned before anything is
+	// unmarshalled. This guards against adversarial input, since the limit
+	// of encoding/json is much higher. Zero means no limit.
+	MaxDepth int
+
+	// NormalizeKeys is called for every top-level key of the JSON object
+	// before it is unmarshalled, the key is replaced with the returned
+	// value. This allows handling upstreams that send keys with inconsistent
+	// casing or naming (e.g. strings.ToLower), both the helper and the
+	// value see the normalized keys. Keys of nested objects are not changed.
+	NormalizeKeys func(key string) string
+
+	// KeepRaw makes the container store a copy of the original input when
+	// unmarshalling, which is then accessible through Container.Raw. This is
+	// useful when the exact bytes are needed, e.g. for auditing or verifying
+	// signatures.
+	KeepRaw bool
+
+	// MarshalRaw makes the container marshal into the original input stored
+	// because of KeepRaw, instead of marshalling the value. This guarantees
+	// byte-exact round trips. Since encoding/json compacts the output of
+	// MarshalJSON, call Container.MarshalJSON directly if whitespace
+	// matters. Note that the stored input is returned even if Value was
+	// changed after unmarshalling, to marshal a changed value assign a new
+	// container (e.g. using New).
+	MarshalRaw bool
+
+	// MarshalRawUnchanged works like MarshalRaw, but the original input
+	// stored because of KeepRaw is only returned if the value was not
+	// changed, otherwise the value is marshalled as usual. This allows
+	// passing objects through byte-for-byte (e.g. in a proxy), while still
+	// marshalling changes. A change is detected by unmarshalling the stored
+	// input again and comparing the marshalled outputs, which makes
+	// marshalling roughly twice as expensive.
+	MarshalRawUnchanged bool
+
+	// IDKey makes the container store the identifier of the object in
+	// Container.ID. When unmarshalling, the field with the key is read into
+	// Container.ID and removed from the object, so it is not unmarshalled
+	// into the value. When marshalling, Container.ID is emitted under the
+	// key right after the fields of the helper, unless it is empty. The
+	// identifier needs to be a JSON string, keys are matched
+	// case-insensitively. The value itself may have a field with the key
+	// (in any casing) only if it is empty (or null), otherwise marshalling
+	// returns ErrIDConflict, since the field would not survive a round trip.
+	IDKey string
+
+	// Validate makes the container validate values implementing Validator
+	// after they are unmarshalled, a failure is returned as a
+	// *ValidationError. Validation is opt-in, so types that happen to have a
+	// Validate method are not validated unexpectedly.
+	Validate bool
+
+	// OnUnknown controls what happens when unmarshalling an object with a
+	// discriminator that the helper does not recognize (i.e. Get returns
+	// nil). By default ErrUnknownType is returned.
+	OnUnknown UnknownStrategy
+
+	// TrimDiscriminator makes the container remove the keys of the helper
+	// from the JSON object before unmarshalling it into the value, so a value
+	// that declares a field for the discriminator leaves it unset. Keys are
+	// matched case-insensitively. This has no effect if Envelope is enabled,
+	// since the discriminator is not part of the value in that case.
+	TrimDiscriminator bool
+
+	// MaxMarshalBytes limits the size of the JSON object produced by
+	// MarshalJSON, if the output is larger, ErrMarshalLimitExceeded is
+	// returned. This allows enforcing message size limits when serializing,
+	// instead of sending an oversized message. Zero means no limit.
+	MaxMarshalBytes int
+
+	// Observer is called after every marshal (OpMarshal) and unmarshal
+	// (OpUnmarshal) with the discriminator and the resulting error, e.g. to
+	// count types and errors in metrics. If the helper has a single string
+	// field, the discriminator is its value, otherwise it is the JSON object
+	// produced by the helper. The discriminator is empty if the helper was
+	// not populated (e.g. the input was not a JSON object). Containers
+	// holding a nil value are marshalled as null without being observed.
+	Observer func(op, typ string, err error)
+
+	// DisableHTMLEscape makes the container write the characters <, > and &
+	// in strings as they are, instead of the escape sequences produced by
+	// encoding/json (e.g. \u003c). It applies to the whole output, including
+	// the fields of the helper and values marshalled by a Codec. Note that
+	// json.Marshal escapes the output of MarshalJSON again, use a
+	// json.Encoder with SetEscapeHTML(false) or call Container.MarshalJSON
+	// directly.
+	DisableHTMLEscape bool
+
+	// EscapeNonASCII makes the container write all non-ASCII characters in
+	// strings as \uXXXX escape sequences, for consumers that can only
+	// handle ASCII. Characters outside the Basic Multilingual Plane (e.g.
+	// emoji) are written as UTF-16 surrogate pairs. Same as
+	// DisableHTMLEscape, it applies to the whole output.
+	EscapeNonASCII bool
+}
+
+// UnknownStrategy determines how a Container handles objects with an unknown
+// type, see Options.OnUnknown.
+type UnknownStrategy int
+
+const (
+	// OnUnknownError makes the container return ErrUnknownType.
+	OnUnknownError UnknownStrategy = iota
+	// OnUnknownSkip makes the container set Value to the zero value and not
+	// return an error.
+	OnUnknownSkip
+	// OnUnknownCapture works the same as OnUnknownSkip, but additionally
+	// stores the object, which is then accessible through Container.Raw.
+	// When marshalling a container holding a captured object, the object is
+	// returned verbatim, so unknown types can be passed through losslessly.
+	OnUnknownCapture
+)
+
+// Codec marshals and unmarshals values of a Container, see Options.Codec.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(b []byte, v any) error
+}
+
+// EnvelopeHelper is an optional interface a Helper can implement to change
+// the key of the field containing the value when Options.Envelope is
+// enabled.
+type EnvelopeHelper interface {
+	DataKey() string
+}
+
+// dataKey returns the key of the field containing the value in envelope mode.
+func dataKey(helper any) string {
+	if e, ok := helper.(EnvelopeHelper); ok {
+		return e.DataKey()
+	}
+	return "data"
+}
+
+// OptionsProvider is an optional interface a Helper can implement to
+// customize how the Container marshals and unmarshals values.
+type OptionsProvider interface {
+	Options() Options
+}
+
+// optionsOf returns the options provided by the helper, or the default
+// options if the helper does not implement OptionsProvider.
+func optionsOf(helper any) Options {
+	if p, ok := helper.(OptionsProvider); ok {
+		return p.Options()
+	}
+	return Options{}
+}
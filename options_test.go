@@ -0,0 +1,1329 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// StrictAnimalHelper rejects duplicate keys.
+type StrictAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *StrictAnimalHelper) Options() Options {
+	return Options{RejectDuplicateKeys: true}
+}
+
+func TestOptions_RejectDuplicateKeys(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+	}{
+		{name: "discriminator", have: `{"type":"dog","name":"Fido","type":"cat"}`},
+		{name: "discriminator_case", have: `{"type":"dog","name":"Fido","Type":"cat"}`},
+		{name: "value", have: `{"type":"dog","name":"Fido","name":"Rex"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var strict Container[Animal, *StrictAnimalHelper]
+			err := json.Unmarshal([]byte(tc.have), &strict)
+			if !errors.Is(err, ErrDuplicateKey) {
+				t.Fatalf("want %v, got %v", ErrDuplicateKey, err)
+			}
+
+			// The default behavior is to accept duplicate keys.
+			var c Container[Animal, *AnimalContainerHelper]
+			if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("no_duplicates", func(t *testing.T) {
+		var c Container[Animal, *StrictAnimalHelper]
+		err := json.Unmarshal([]byte(`{"type":"dog","name":"Fido"}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// VeryStrictAnimalHelper rejects duplicate keys at any depth.
+type VeryStrictAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *VeryStrictAnimalHelper) Options() Options {
+	return Options{RejectAnyDuplicateKeys: true}
+}
+
+func TestOptions_RejectAnyDuplicateKeys(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    string
+		wantErr string
+	}{{
+		name:    "top_level",
+		have:    `{"type":"dog","name":"Fido","Name":"Rex"}`,
+		wantErr: `duplicate key "Name" at $`,
+	}, {
+		name:    "nested",
+		have:    `{"type":"owned-dog","name":"Fido","owner":{"role":"breeder","name":"Alice","name":"Bob"}}`,
+		wantErr: `duplicate key "name" at $.owner`,
+	}, {
+		name:    "array",
+		have:    `{"type":"dog","tags":[{"a":1},{"b":1,"b":2}]}`,
+		wantErr: `duplicate key "b" at $.tags[1]`,
+	}, {
+		name:    "nested_arrays",
+		have:    `{"type":"dog","matrix":[[],[{"x":{"y":1,"y":2}}]]}`,
+		wantErr: `duplicate key "y" at $.matrix[1][0].x`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var strict Container[Animal, *VeryStrictAnimalHelper]
+			err := json.Unmarshal([]byte(tc.have), &strict)
+			if !errors.Is(err, ErrDuplicateKey) {
+				t.Fatalf("want %v, got %v", ErrDuplicateKey, err)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("want error containing %q, got %v", tc.wantErr, err)
+			}
+
+			// The default behavior is to accept duplicate keys.
+			var c Container[Animal, *AnimalContainerHelper]
+			if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("deep", func(t *testing.T) {
+		// The input is nested much deeper than encoding/json allows, which
+		// must not exhaust the stack or take quadratic time.
+		const depth = 200000
+		have := `{"type":"dog","a":` + strings.Repeat("[", depth) + `{"x":1,"x":2}` + strings.Repeat("]", depth) + `}`
+		var c Container[Animal, *VeryStrictAnimalHelper]
+		err := c.UnmarshalJSON([]byte(have))
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("want %v, got %v", ErrDuplicateKey, err)
+		}
+		if want := `$.a` + strings.Repeat("[0]", depth); !strings.HasSuffix(err.Error(), want) {
+			t.Fatalf("want error ending with the path of the object")
+		}
+	})
+
+	t.Run("mismatched_brackets", func(t *testing.T) {
+		var c Container[Animal, *VeryStrictAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(`{"type":"dog","a":[1}]}`)); !errors.Is(err, errInvalidJSON) {
+			t.Fatalf("want %v, got %v", errInvalidJSON, err)
+		}
+	})
+
+	t.Run("no_duplicates", func(t *testing.T) {
+		var c Container[Animal, *VeryStrictAnimalHelper]
+		// The same key in different objects is not a duplicate.
+		err := json.Unmarshal([]byte(`{"type":"owned-dog","name":"Fido","owner":{"role":"breeder","name":"Alice"},"tags":[{"name":1},{"name":2}],"s":"{\"name\":1,\"name\":2}"}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// AnimalUnion is a tagged union of known animals.
+type AnimalUnion struct {
+	Dog *Dog
+	Cat *Cat
+}
+
+type AnimalUnionHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AnimalUnionHelper) Options() Options {
+	return Options{Union: true}
+}
+
+func (h *AnimalUnionHelper) Get() AnimalUnion {
+	switch h.Type {
+	case "dog":
+		return AnimalUnion{Dog: &Dog{}}
+	case "cat":
+		return AnimalUnion{Cat: &Cat{}}
+	}
+	return AnimalUnion{}
+}
+
+func (h *AnimalUnionHelper) Set(u AnimalUnion) {
+	switch {
+	case u.Dog != nil:
+		h.Type = u.Dog.Type()
+	case u.Cat != nil:
+		h.Type = u.Cat.Type()
+	}
+}
+
+func TestOptions_Union(t *testing.T) {
+	t.Run("round_trip", func(t *testing.T) {
+		have := AnimalUnion{Cat: &Cat{XName: "Whiskers", Owner: "Alice", Color: "White"}}
+		want := `{"type":"cat","name":"Whiskers","owner":"Alice","color":"White"}`
+		testRoundTrip[AnimalUnion, *AnimalUnionHelper](t, have, want)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		var c Container[AnimalUnion, *AnimalUnionHelper]
+		err := json.Unmarshal([]byte(`{"type":"dolphin"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, u := range []AnimalUnion{{}, {Dog: &Dog{}, Cat: &Cat{}}} {
+			_, err := json.Marshal(New[AnimalUnion, *AnimalUnionHelper](u))
+			if !errors.Is(err, ErrInvalidUnion) {
+				t.Fatalf("want %v, got %v", ErrInvalidUnion, err)
+			}
+		}
+	})
+}
+
+// DogChannelHelper is used where every value is known to be a dog.
+type DogChannelHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *DogChannelHelper) Options() Options {
+	return Options{IgnoreTypeOnUnmarshal: true}
+}
+
+func (h *DogChannelHelper) Get() Animal {
+	return Dog{}
+}
+
+func (h *DogChannelHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestOptions_IgnoreTypeOnUnmarshal(t *testing.T) {
+	want := Dog{XName: "Fido", Breed: "Golden Retriever"}
+
+	for _, raw := range []string{
+		`{"name":"Fido","breed":"Golden Retriever"}`,
+		`{"type":"cat","name":"Fido","breed":"Golden Retriever"}`,
+	} {
+		var c Container[Animal, *DogChannelHelper]
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	}
+
+	got, err := json.Marshal(New[Animal, *DogChannelHelper](want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `{"type":"dog","name":"Fido","breed":"Golden Retriever"}`
+	if string(got) != wantJSON {
+		t.Fatalf("want %s, got %s", wantJSON, string(got))
+	}
+}
+
+// Transaction stores the amount in a field of type any.
+type Transaction struct {
+	Amount any `json:"amount"`
+}
+
+type TransactionHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *TransactionHelper) Options() Options {
+	return Options{UseNumber: true}
+}
+
+func (h *TransactionHelper) Get() any {
+	return Transaction{}
+}
+
+func (h *TransactionHelper) Set(any) {
+	h.Type = "transaction"
+}
+
+func TestOptions_UseNumber(t *testing.T) {
+	// 2^53+1 can not be represented as float64.
+	raw := `{"type":"transaction","amount":9007199254740993}`
+
+	var c Container[any, *TransactionHelper]
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Transaction{Amount: json.Number("9007199254740993")}
+	if c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != raw {
+		t.Fatalf("want %s, got %s", raw, string(got))
+	}
+}
+
+type EnvelopeAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *EnvelopeAnimalHelper) Options() Options {
+	return Options{Envelope: true}
+}
+
+type AttributesAnimalHelper struct {
+	EnvelopeAnimalHelper
+}
+
+func (h *AttributesAnimalHelper) DataKey() string {
+	return "attributes"
+}
+
+type PayloadAnimalHelper struct {
+	EnvelopeAnimalHelper
+}
+
+func (h *PayloadAnimalHelper) DataKey() string {
+	return "payload"
+}
+
+func TestOptions_Envelope(t *testing.T) {
+	have := Dog{XName: "Fido", Breed: "Golden Retriever"}
+
+	t.Run("data", func(t *testing.T) {
+		want := `{"type":"dog","data":{"name":"Fido","breed":"Golden Retriever"}}`
+		testRoundTrip[Animal, *EnvelopeAnimalHelper](t, have, want)
+	})
+	t.Run("attributes", func(t *testing.T) {
+		want := `{"type":"dog","attributes":{"name":"Fido","breed":"Golden Retriever"}}`
+		testRoundTrip[Animal, *AttributesAnimalHelper](t, have, want)
+	})
+	t.Run("payload", func(t *testing.T) {
+		want := `{"type":"dog","payload":{"name":"Fido","breed":"Golden Retriever"}}`
+		testRoundTrip[Animal, *PayloadAnimalHelper](t, have, want)
+	})
+
+	t.Run("wrong_key", func(t *testing.T) {
+		// The value is not found under a different key, and top-level fields
+		// are not unmarshalled into the value.
+		var c Container[Animal, *PayloadAnimalHelper]
+		err := json.Unmarshal([]byte(`{"type":"dog","name":"Rex","data":{"name":"Fido"}}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != (Dog{}) {
+			t.Fatalf("want empty dog, got %v", c.Value)
+		}
+	})
+}
+
+type JSONCAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *JSONCAnimalHelper) Options() Options {
+	return Options{JSONC: true}
+}
+
+func TestOptions_JSONC(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+	}{{
+		name: "plain",
+		have: `{"type":"dog","name":"Fido","breed":"Pug"}`,
+	}, {
+		name: "line_comments",
+		have: `// A dog.
+{
+	"type": "dog", // The discriminator.
+	"name": "Fido",
+	"breed": "Pug" // Trailing comment.
+}`,
+	}, {
+		name: "block_comments",
+		have: `/* A dog. */ {"type":/* inline */"dog","name":"Fido",
+		/*
+		 * Multi-line comment.
+		 */
+		"breed":"Pug"}`,
+	}, {
+		name: "trailing_commas",
+		have: `{"type":"dog","name":"Fido","breed":"Pug",}`,
+	}, {
+		name: "trailing_comma_before_comment",
+		have: `{
+	"type": "dog",
+	"name": "Fido",
+	"breed": "Pug", // Trailing comma followed by a comment.
+}`,
+	}}
+
+	want := Dog{XName: "Fido", Breed: "Pug"}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[Animal, *JSONCAnimalHelper]
+			if err := c.UnmarshalJSON([]byte(tc.have)); err != nil {
+				t.Fatal(err)
+			}
+			if c.Value != want {
+				t.Fatalf("want %v, got %v", want, c.Value)
+			}
+		})
+	}
+
+	t.Run("strings", func(t *testing.T) {
+		// Comment markers and trailing commas in strings are preserved.
+		have := `{"type":"dog","name":"// Fido /* ,}","breed":"Pug,]"}`
+		var c Container[Animal, *JSONCAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if want := (Dog{XName: "// Fido /* ,}", Breed: "Pug,]"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("nested_array", func(t *testing.T) {
+		have := `{"type":"dog",/* hidden */"name":"Fido","tags":[1,2,],}`
+		var c Container[map[string]any, *JSONCMapHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Value["tags"].([]any); len(got) != 2 {
+			t.Fatalf("want 2 tags, got %v", got)
+		}
+	})
+
+	t.Run("unterminated_comment", func(t *testing.T) {
+		var c Container[Animal, *JSONCAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(`{"type":"dog" /* `)); !errors.Is(err, errInvalidJSON) {
+			t.Fatalf("want %v, got %v", errInvalidJSON, err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalJSON([]byte(`{"type":"dog",}`)); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		var c Container[Animal, *JSONCRawAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(`{"type":"dog",/* A dog. */"name":"Fido",}`)); err != nil {
+			t.Fatal(err)
+		}
+		// The stored input is stripped, only whitespace remains.
+		if got := c.Raw(); !json.Valid(got) {
+			t.Fatalf("want valid JSON, got %s", got)
+		}
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dog","name":"Fido"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		var c Container[Animal, *JSONCRawAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(`{"type":"dolphin",/* unknown */"name":"Flipper",}`)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dolphin","name":"Flipper"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+}
+
+// JSONCRawAnimalHelper stores JSONC input and marshals it back.
+type JSONCRawAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *JSONCRawAnimalHelper) Options() Options {
+	return Options{JSONC: true, KeepRaw: true, MarshalRaw: true, OnUnknown: OnUnknownCapture}
+}
+
+type JSONCMapHelper struct {
+	MapHelper
+}
+
+func (h *JSONCMapHelper) Options() Options {
+	return Options{JSONC: true}
+}
+
+// FixedDogHelper has no discriminator, it always returns a Dog.
+type FixedDogHelper struct{}
+
+func (h *FixedDogHelper) Options() Options {
+	return Options{Untyped: true}
+}
+
+func (h *FixedDogHelper) Get() Animal {
+	return Dog{}
+}
+
+func (h *FixedDogHelper) Set(Animal) {}
+
+func TestOptions_Untyped(t *testing.T) {
+	have := Dog{XName: "Fido", Breed: "Pug"}
+	want := `{"name":"Fido","breed":"Pug"}`
+	testRoundTrip[Animal, *FixedDogHelper](t, have, want)
+
+	t.Run("discriminator_ignored", func(t *testing.T) {
+		var c Container[Animal, *FixedDogHelper]
+		err := json.Unmarshal([]byte(`{"type":"cat","name":"Fido","breed":"Pug"}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != have {
+			t.Fatalf("want %v, got %v", have, c.Value)
+		}
+	})
+}
+
+// countingCodec delegates to encoding/json and counts the calls.
+type countingCodec struct {
+	marshal, unmarshal *int
+}
+
+func (c countingCodec) Marshal(v any) ([]byte, error) {
+	*c.marshal++
+	return json.Marshal(v)
+}
+
+func (c countingCodec) Unmarshal(b []byte, v any) error {
+	*c.unmarshal++
+	return json.Unmarshal(b, v)
+}
+
+var codecMarshalCalls, codecUnmarshalCalls int
+
+type CodecAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *CodecAnimalHelper) Options() Options {
+	return Options{Codec: countingCodec{
+		marshal:   &codecMarshalCalls,
+		unmarshal: &codecUnmarshalCalls,
+	}}
+}
+
+func TestOptions_Codec(t *testing.T) {
+	codecMarshalCalls, codecUnmarshalCalls = 0, 0
+
+	have := Dog{XName: "Fido", Breed: "Pug"}
+	want := `{"type":"dog","name":"Fido","breed":"Pug"}`
+	testRoundTrip[Animal, *CodecAnimalHelper](t, have, want)
+
+	if codecMarshalCalls == 0 || codecUnmarshalCalls == 0 {
+		t.Fatalf("expected codec to be used, got %d marshal and %d unmarshal calls", codecMarshalCalls, codecUnmarshalCalls)
+	}
+}
+
+type SortedAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *SortedAnimalHelper) Options() Options {
+	return Options{SortKeys: true}
+}
+
+func TestOptions_SortKeys(t *testing.T) {
+	// Cat declares its fields in the order name, owner, color.
+	have := Cat{XName: "Tom", Owner: "Alice", Color: "black"}
+	want := `{"type":"cat","color":"black","name":"Tom","owner":"Alice"}`
+	testRoundTrip[Animal, *SortedAnimalHelper](t, have, want)
+
+	t.Run("stable", func(t *testing.T) {
+		c := New[Animal, *SortedAnimalHelper](have)
+		first, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			got, err := json.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(first) {
+				t.Fatalf("output changed between runs: %s != %s", got, first)
+			}
+		}
+	})
+
+	t.Run("discriminator_in_value", func(t *testing.T) {
+		// Bird declares the discriminator, which is still emitted first.
+		c := New[Animal, *SortedAnimalHelper](Bird{XName: "Tweety"})
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(got), `{"type":"bird",`) {
+			t.Fatalf("expected discriminator first, got %s", got)
+		}
+	})
+}
+
+type ShallowMapHelper struct {
+	MapHelper
+}
+
+func (h *ShallowMapHelper) Options() Options {
+	return Options{MaxDepth: 3}
+}
+
+func TestOptions_MaxDepth(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    string
+		wantErr error
+	}{{
+		name: "flat",
+		have: `{"type":"dog","name":"Fido"}`,
+	}, {
+		name: "at_limit",
+		have: `{"type":"dog","a":{"b":[1,2]}}`,
+	}, {
+		name: "brackets_in_strings",
+		have: `{"type":"dog","a":{"b":"[[[{{{"}}`,
+	}, {
+		name:    "object_too_deep",
+		have:    `{"type":"dog","a":{"b":{"c":{}}}}`,
+		wantErr: ErrMaxDepthExceeded,
+	}, {
+		name:    "array_too_deep",
+		have:    `{"type":"dog","a":[[[1]]]}`,
+		wantErr: ErrMaxDepthExceeded,
+	}, {
+		name:    "adversarial",
+		have:    `{"type":"dog","a":` + strings.Repeat("[", 1000) + strings.Repeat("]", 1000) + `}`,
+		wantErr: ErrMaxDepthExceeded,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[map[string]any, *ShallowMapHelper]
+			err := json.Unmarshal([]byte(tc.have), &c)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("mismatched_brackets", func(t *testing.T) {
+		// json.Unmarshal would reject the input before calling the
+		// container, so UnmarshalJSON is called directly.
+		for _, have := range []string{
+			`{"type":"dog","a":{]}`,
+			`{"type":"dog","a":[}}`,
+			`{"type":"dog","a":]]]]}`,
+		} {
+			var c Container[map[string]any, *ShallowMapHelper]
+			err := c.UnmarshalJSON([]byte(have))
+			if !errors.Is(err, errInvalidJSON) {
+				t.Fatalf("%s: want %v, got %v", have, errInvalidJSON, err)
+			}
+		}
+	})
+}
+
+type LowerKeysMapHelper struct {
+	Kind string `json:"kind"`
+}
+
+func (h *LowerKeysMapHelper) Options() Options {
+	return Options{NormalizeKeys: strings.ToLower}
+}
+
+func (h *LowerKeysMapHelper) Get() map[string]any {
+	if h.Kind == "event" {
+		return map[string]any{}
+	}
+	return nil
+}
+
+func (h *LowerKeysMapHelper) Set(m map[string]any) {
+	h.Kind, _ = m["kind"].(string)
+}
+
+func TestOptions_NormalizeKeys(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+	}{{
+		name: "lower",
+		have: `{"kind":"event","id":1,"payload":{"A":1}}`,
+	}, {
+		name: "upper",
+		have: `{"KIND":"event","ID":1,"PAYLOAD":{"A":1}}`,
+	}, {
+		name: "mixed",
+		have: `{"Kind":"event","iD":1,"PayLoad":{"A":1}}`,
+	}}
+
+	// Nested keys are left untouched.
+	want := map[string]any{"kind": "event", "id": float64(1), "payload": map[string]any{"A": float64(1)}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[map[string]any, *LowerKeysMapHelper]
+			if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Value, want) {
+				t.Fatalf("want %v, got %v", want, c.Value)
+			}
+		})
+	}
+}
+
+type RawKeepingAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *RawKeepingAnimalHelper) Options() Options {
+	return Options{KeepRaw: true}
+}
+
+type RawMarshallingAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *RawMarshallingAnimalHelper) Options() Options {
+	return Options{KeepRaw: true, MarshalRaw: true}
+}
+
+type PassThroughAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *PassThroughAnimalHelper) Options() Options {
+	return Options{KeepRaw: true, MarshalRawUnchanged: true}
+}
+
+func TestOptions_MarshalRawUnchanged(t *testing.T) {
+	have := `{ "breed": "P\u0075g", "type": "dog", "name": "Fido" }`
+
+	unmarshal := func(t *testing.T) Container[Animal, *PassThroughAnimalHelper] {
+		var c Container[Animal, *PassThroughAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		c := unmarshal(t)
+		got, err := c.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != have {
+			t.Fatalf("want %s, got %s", have, got)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		c := unmarshal(t)
+		c.Value = Dog{XName: "Rex", Breed: "Pug"}
+		got, err := c.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dog","name":"Rex","breed":"Pug"}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("changed_type", func(t *testing.T) {
+		c := unmarshal(t)
+		c.Value = Cat{XName: "Fido"}
+		got, err := c.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"cat","name":"Fido","owner":"","color":""}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("changed_back", func(t *testing.T) {
+		c := unmarshal(t)
+		c.Value = Dog{XName: "Fido", Breed: "Pug"}
+		got, err := c.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != have {
+			t.Fatalf("want %s, got %s", have, got)
+		}
+	})
+}
+
+func TestOptions_KeepRaw(t *testing.T) {
+	have := `{ "name": "Fido",  "type": "dog" }`
+
+	t.Run("keep", func(t *testing.T) {
+		var c Container[Animal, *RawKeepingAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if string(c.Raw()) != have {
+			t.Fatalf("want %s, got %s", have, c.Raw())
+		}
+
+		// The value is marshalled as usual.
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dog","name":"Fido","breed":""}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("marshal_raw", func(t *testing.T) {
+		var c Container[Animal, *RawMarshallingAnimalHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != have {
+			t.Fatalf("want %s, got %s", have, got)
+		}
+
+		// A new container does not hold the input.
+		got, err = json.Marshal(New[Animal, *RawMarshallingAnimalHelper](c.Value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dog","name":"Fido","breed":""}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+
+	t.Run("input_copied", func(t *testing.T) {
+		b := []byte(have)
+		var c Container[Animal, *RawKeepingAnimalHelper]
+		if err := c.UnmarshalJSON(b); err != nil {
+			t.Fatal(err)
+		}
+		copy(b, "xxxxxxxx")
+		if string(c.Raw()) != have {
+			t.Fatalf("want %s, got %s", have, c.Raw())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := c.UnmarshalJSON([]byte(have)); err != nil {
+			t.Fatal(err)
+		}
+		if c.Raw() != nil {
+			t.Fatalf("expected no raw input, got %s", c.Raw())
+		}
+	})
+}
+
+type SkipUnknownHelper struct {
+	AnimalRegistryHelper
+}
+
+func (h *SkipUnknownHelper) Options() Options {
+	return Options{OnUnknown: OnUnknownSkip}
+}
+
+type CaptureUnknownHelper struct {
+	AnimalRegistryHelper
+}
+
+func (h *CaptureUnknownHelper) Options() Options {
+	return Options{OnUnknown: OnUnknownCapture}
+}
+
+func TestOptions_OnUnknown(t *testing.T) {
+	unknown := `{"type":"dolphin","name":"Cooper"}`
+
+	t.Run("error", func(t *testing.T) {
+		var c Container[Animal, *AnimalRegistryHelper]
+		if err := json.Unmarshal([]byte(unknown), &c); !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		c := New[Animal, *SkipUnknownHelper](Dog{XName: "Fido"})
+		if err := json.Unmarshal([]byte(unknown), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != nil {
+			t.Fatalf("want nil, got %v", c.Value)
+		}
+		if c.Raw() != nil {
+			t.Fatalf("want no raw input, got %s", c.Raw())
+		}
+
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != `null` {
+			t.Fatalf("want null, got %s", got)
+		}
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		var c Container[Animal, *CaptureUnknownHelper]
+		if err := json.Unmarshal([]byte(unknown), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != nil {
+			t.Fatalf("want nil, got %v", c.Value)
+		}
+		if string(c.Raw()) != unknown {
+			t.Fatalf("want %s, got %s", unknown, c.Raw())
+		}
+
+		// The captured object is passed through.
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != unknown {
+			t.Fatalf("want %s, got %s", unknown, got)
+		}
+
+		// Known types are not captured.
+		if err := json.Unmarshal([]byte(`{"type":"dog","name":"Fido"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Raw() != nil {
+			t.Fatalf("want no raw input, got %s", c.Raw())
+		}
+	})
+
+	t.Run("missing_type", func(t *testing.T) {
+		// Only unknown types are handled by the strategy.
+		var c Container[Animal, *CaptureUnknownHelper]
+		if err := json.Unmarshal([]byte(`{"name":"Fido"}`), &c); !errors.Is(err, ErrMissingType) {
+			t.Fatalf("want %v, got %v", ErrMissingType, err)
+		}
+	})
+}
+
+type TrimmingAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *TrimmingAnimalHelper) Options() Options {
+	return Options{TrimDiscriminator: true}
+}
+
+type TrimmingMapHelper struct {
+	MapHelper
+}
+
+func (h *TrimmingMapHelper) Options() Options {
+	return Options{TrimDiscriminator: true}
+}
+
+func TestOptions_TrimDiscriminator(t *testing.T) {
+	t.Run("struct", func(t *testing.T) {
+		// Bird declares the discriminator field, which is left unset.
+		var c Container[Animal, *TrimmingAnimalHelper]
+		if err := json.Unmarshal([]byte(`{"TYPE":"bird","name":"Tweety"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if want := (Bird{XName: "Tweety"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		var c Container[map[string]any, *TrimmingMapHelper]
+		if err := json.Unmarshal([]byte(`{"type":"event","a":1}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]any{"a": float64(1)}; !reflect.DeepEqual(c.Value, want) {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var c Container[Animal, *AnimalContainerHelper]
+		if err := json.Unmarshal([]byte(`{"type":"bird","name":"Tweety"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if want := (Bird{XType: "bird", XName: "Tweety"}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+}
+
+// Pack is a slice of dogs, it marshals into a JSON array.
+type Pack []Dog
+
+func (Pack) Type() string {
+	return "pack"
+}
+func (p Pack) Name() string {
+	return fmt.Sprintf("pack of %d", len(p))
+}
+
+// Kennel maps names of dogs to dogs, it marshals into a JSON object.
+type Kennel map[string]Dog
+
+func (Kennel) Type() string {
+	return "kennel"
+}
+func (k Kennel) Name() string {
+	return fmt.Sprintf("kennel of %d", len(k))
+}
+
+type GroupEnvelopeHelper struct {
+	EnvelopeAnimalHelper
+}
+
+func (h *GroupEnvelopeHelper) Get() Animal {
+	switch h.Type {
+	case "pack":
+		return Pack{}
+	case "kennel":
+		return Kennel{}
+	}
+	return h.EnvelopeAnimalHelper.Get()
+}
+
+func TestOptions_EnvelopeCollections(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "slice",
+		have: Pack{{XName: "Fido"}, {XName: "Rex", Breed: "Pug"}},
+		want: `{"type":"pack","data":[{"name":"Fido","breed":""},{"name":"Rex","breed":"Pug"}]}`,
+	}, {
+		name: "empty_slice",
+		have: Pack{},
+		want: `{"type":"pack","data":[]}`,
+	}, {
+		name: "map",
+		have: Kennel{"b": {XName: "Rex"}, "a": {XName: "Fido"}},
+		want: `{"type":"kennel","data":{"a":{"name":"Fido","breed":""},"b":{"name":"Rex","breed":""}}}`,
+	}, {
+		name: "pointer_to_slice",
+		have: &Pack{{XName: "Fido"}},
+		want: `{"type":"pack","data":[{"name":"Fido","breed":""}]}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(New[Animal, *GroupEnvelopeHelper](tc.have))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var c Container[Animal, *GroupEnvelopeHelper]
+		err := json.Unmarshal([]byte(`{"type":"pack","data":[{"name":"Fido"},{"name":"Rex"}]}`), &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Pack{{XName: "Fido"}, {XName: "Rex"}}
+		if !reflect.DeepEqual(c.Value, want) {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+
+	t.Run("without_envelope", func(t *testing.T) {
+		// Arrays can not be merged with the discriminator.
+		_, err := json.Marshal(New[Animal, *AnimalContainerHelper](Pack{{XName: "Fido"}}))
+		if !errors.Is(err, ErrNotJSONObject) {
+			t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+		}
+	})
+}
+
+// Nickname is an animal that marshals into a JSON string.
+type Nickname string
+
+func (Nickname) Type() string {
+	return "nickname"
+}
+func (n Nickname) Name() string {
+	return string(n)
+}
+
+// DataDog is a dog with a field that has the same key as the envelope.
+type DataDog struct {
+	XName string `json:"name"`
+	Data  string `json:"data"`
+}
+
+func (DataDog) Type() string {
+	return "datadog"
+}
+func (d DataDog) Name() string {
+	return d.XName
+}
+
+type AutoEnvelopeAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *AutoEnvelopeAnimalHelper) Get() Animal {
+	switch h.Type {
+	case "pack":
+		return Pack{}
+	case "nickname":
+		return Nickname("")
+	case "datadog":
+		return DataDog{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func (h *AutoEnvelopeAnimalHelper) Options() Options {
+	return Options{AutoEnvelope: true}
+}
+
+func TestOptions_AutoEnvelope(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "object",
+		have: Dog{XName: "Fido", Breed: "Pug"},
+		want: `{"type":"dog","name":"Fido","breed":"Pug"}`,
+	}, {
+		name: "array",
+		have: Pack{{XName: "Fido"}, {XName: "Rex"}},
+		want: `{"type":"pack","data":[{"name":"Fido","breed":""},{"name":"Rex","breed":""}]}`,
+	}, {
+		name: "empty_array",
+		have: Pack{},
+		want: `{"type":"pack","data":[]}`,
+	}, {
+		name: "scalar",
+		have: Nickname("Fido"),
+		want: `{"type":"nickname","data":"Fido"}`,
+	}, {
+		name: "object_with_data_key",
+		have: DataDog{XName: "Fido", Data: "secret"},
+		want: `{"type":"datadog","data":{"name":"Fido","data":"secret"}}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Animal, *AutoEnvelopeAnimalHelper](t, tc.have, tc.want)
+		})
+	}
+
+	t.Run("separate", func(t *testing.T) {
+		got, err := DecodeSeparate[Animal, *AutoEnvelopeAnimalHelper]("nickname", []byte(`"Fido"`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := Nickname("Fido"); got != want {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	})
+}
+
+// ChippedDog has a field with the same key as the ID of the container.
+type ChippedDog struct {
+	ID    string `json:"id"`
+	XName string `json:"name"`
+}
+
+func (ChippedDog) Type() string {
+	return "chipped"
+}
+func (d ChippedDog) Name() string {
+	return d.XName
+}
+
+// TaggedCat has an untagged ID field, which is marshalled under the key "ID".
+type TaggedCat struct {
+	ID    string
+	XName string `json:"name"`
+}
+
+func (TaggedCat) Type() string {
+	return "tagged"
+}
+func (c TaggedCat) Name() string {
+	return c.XName
+}
+
+type IdentifiedAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *IdentifiedAnimalHelper) Get() Animal {
+	switch h.Type {
+	case "chipped":
+		return ChippedDog{}
+	case "tagged":
+		return TaggedCat{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func (h *IdentifiedAnimalHelper) Options() Options {
+	return Options{IDKey: "id"}
+}
+
+func TestOptions_IDKey(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Container[Animal, *IdentifiedAnimalHelper]
+		want string
+	}{{
+		name: "with_id",
+		have: Container[Animal, *IdentifiedAnimalHelper]{Value: Dog{XName: "Fido"}, ID: "42"},
+		want: `{"type":"dog","id":"42","name":"Fido","breed":""}`,
+	}, {
+		name: "without_id",
+		have: Container[Animal, *IdentifiedAnimalHelper]{Value: Dog{XName: "Fido"}},
+		want: `{"type":"dog","name":"Fido","breed":""}`,
+	}, {
+		name: "value_with_id_field",
+		have: Container[Animal, *IdentifiedAnimalHelper]{Value: ChippedDog{XName: "Fido"}, ID: "42"},
+		want: `{"type":"chipped","id":"42","name":"Fido"}`,
+	}, {
+		name: "value_with_id_field_other_case",
+		have: Container[Animal, *IdentifiedAnimalHelper]{Value: TaggedCat{XName: "Tom"}, ID: "42"},
+		want: `{"type":"tagged","id":"42","name":"Tom"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := json.Marshal(tc.have)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+
+			var c Container[Animal, *IdentifiedAnimalHelper]
+			if err := json.Unmarshal(got, &c); err != nil {
+				t.Fatal(err)
+			}
+			if c != tc.have {
+				t.Fatalf("want %v, got %v", tc.have, c)
+			}
+		})
+	}
+
+	t.Run("not_read_into_value", func(t *testing.T) {
+		var c Container[Animal, *IdentifiedAnimalHelper]
+		if err := json.Unmarshal([]byte(`{"type":"chipped","ID":"42","name":"Fido"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		want := Container[Animal, *IdentifiedAnimalHelper]{Value: ChippedDog{XName: "Fido"}, ID: "42"}
+		if c != want {
+			t.Fatalf("want %v, got %v", want, c)
+		}
+	})
+
+	t.Run("value_owns_id", func(t *testing.T) {
+		for _, v := range []Animal{ChippedDog{ID: "x", XName: "Fido"}, TaggedCat{ID: "x", XName: "Tom"}} {
+			for _, id := range []string{"", "42"} {
+				c := Container[Animal, *IdentifiedAnimalHelper]{Value: v, ID: id}
+				_, err := json.Marshal(c)
+				if !errors.Is(err, ErrIDConflict) {
+					t.Fatalf("%T with container ID %q: want %v, got %v", v, id, ErrIDConflict, err)
+				}
+			}
+		}
+	})
+
+	t.Run("not_string", func(t *testing.T) {
+		var c Container[Animal, *IdentifiedAnimalHelper]
+		if err := json.Unmarshal([]byte(`{"type":"dog","id":42}`), &c); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		got, err := json.Marshal(Container[Animal, *AnimalContainerHelper]{Value: Dog{XName: "Fido"}, ID: "42"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"dog","name":"Fido","breed":""}`; string(got) != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	})
+}
+
+type LimitedAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *LimitedAnimalHelper) Options() Options {
+	return Options{MaxMarshalBytes: 41}
+}
+
+func TestOptions_MaxMarshalBytes(t *testing.T) {
+	t.Run("within_limit", func(t *testing.T) {
+		// Exactly 41 bytes.
+		want := `{"type":"dog","name":"Rex","breed":"Pug"}`
+		testRoundTrip[Animal, *LimitedAnimalHelper](t, Dog{XName: "Rex", Breed: "Pug"}, want)
+	})
+
+	t.Run("exceeds_limit", func(t *testing.T) {
+		c := New[Animal, *LimitedAnimalHelper](Dog{XName: "Fido", Breed: "Golden Retriever"})
+		_, err := json.Marshal(c)
+		if !errors.Is(err, ErrMarshalLimitExceeded) {
+			t.Fatalf("want %v, got %v", ErrMarshalLimitExceeded, err)
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		got, err := json.Marshal(Container[Animal, *LimitedAnimalHelper]{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "null" {
+			t.Fatalf("want null, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package jsonpoly
+
+// Pool is an optional interface a Helper can implement to reuse values
+// instead of allocating a new value for every decoded object, which reduces
+// the pressure on the garbage collector in high-throughput decoding. Only
+// pointer values can be reused, since values are copied anyway.
+//
+// The helper obtains values from the pool in Get and returns them to the pool
+// in Release. Since encoding/json merges the input into the existing fields
+// of a value, Get needs to return a value that is reset to its zero state
+// (or Release needs to reset it before putting it back).
+//
+// The container owns the value only while it is being unmarshalled, if
+// unmarshalling fails, the container releases the value obtained from Get.
+// Once a value is decoded, the caller owns it and is responsible for
+// returning it to the pool by calling Container.Release. A released value
+// must not be used anymore, this includes copies of the container holding
+// the same pointer and values referencing it (e.g. slices or maps in its
+// fields that were kept after the release). Values that are never released
+// are simply collected by the garbage collector.
+type Pool[V any] interface {
+	Release(v V)
+}
+
+// Release returns the value to the pool of the helper, if the helper
+// implements Pool, and resets the container to its zero value. The value
+// must not be used after it is released, see Pool for details.
+func (c *Container[V, H]) Release() {
+	helper, err := newHelper[V, H]()
+	if err == nil && !isNil(c.Value) {
+		release(helper, c.Value)
+	}
+	var zero V
+	c.Value, c.ID, c.raw = zero, "", ""
+}
+
+// release returns v to the pool of the helper, if the helper implements Pool.
+func release[V any](helper Helper[V], v V) {
+	if p, ok := helper.(Pool[V]); ok {
+		p.Release(v)
+	}
+}
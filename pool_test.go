@@ -0,0 +1,134 @@
+package jsonpoly
+
+import (
+	"sync"
+	"testing"
+)
+
+// dogPool is a free list of dogs, so tests can check which dogs were
+// released.
+var dogPool struct {
+	sync.Mutex
+	free []*Dog
+}
+
+// PooledAnimalHelper obtains dogs from dogPool.
+type PooledAnimalHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *PooledAnimalHelper) Get() Animal {
+	switch h.Type {
+	case "dog":
+		dogPool.Lock()
+		defer dogPool.Unlock()
+		if n := len(dogPool.free); n > 0 {
+			d := dogPool.free[n-1]
+			dogPool.free = dogPool.free[:n-1]
+			return d
+		}
+		return new(Dog)
+	case "cat":
+		return Cat{}
+	}
+	return nil
+}
+
+func (h *PooledAnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func (h *PooledAnimalHelper) Release(a Animal) {
+	if d, ok := a.(*Dog); ok {
+		*d = Dog{}
+		dogPool.Lock()
+		defer dogPool.Unlock()
+		dogPool.free = append(dogPool.free, d)
+	}
+}
+
+func TestContainer_Release(t *testing.T) {
+	var c Container[Animal, *PooledAnimalHelper]
+	if err := c.UnmarshalJSON([]byte(`{"type":"dog","name":"Fido","breed":"Pug"}`)); err != nil {
+		t.Fatal(err)
+	}
+	first := c.Value.(*Dog)
+	if want := (Dog{XName: "Fido", Breed: "Pug"}); *first != want {
+		t.Fatalf("want %v, got %v", want, *first)
+	}
+
+	c.Release()
+	if c.Value != nil {
+		t.Fatalf("want nil value, got %v", c.Value)
+	}
+
+	// The released dog is reused and does not keep the old fields.
+	if err := c.UnmarshalJSON([]byte(`{"type":"dog","name":"Rex"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Value.(*Dog) != first {
+		t.Fatal("expected the released dog to be reused")
+	}
+	if want := (Dog{XName: "Rex"}); *first != want {
+		t.Fatalf("want %v, got %v", want, *first)
+	}
+	c.Release()
+}
+
+func TestContainer_ReleaseOnError(t *testing.T) {
+	var c Container[Animal, *PooledAnimalHelper]
+	if err := c.UnmarshalJSON([]byte(`{"type":"dog","name":1}`)); err == nil {
+		t.Fatal("expected error")
+	}
+
+	dogPool.Lock()
+	defer dogPool.Unlock()
+	if len(dogPool.free) == 0 {
+		t.Fatal("expected the dog to be released")
+	}
+	if d := dogPool.free[len(dogPool.free)-1]; *d != (Dog{}) {
+		t.Fatalf("want reset dog, got %v", *d)
+	}
+}
+
+func TestContainer_ReleaseWithoutPool(t *testing.T) {
+	c := New[Animal, *AnimalContainerHelper](Dog{XName: "Fido"})
+	c.Release()
+	if c.Value != nil {
+		t.Fatalf("want nil value, got %v", c.Value)
+	}
+
+	// Values that are not pointers are not released.
+	pc := Container[Animal, *PooledAnimalHelper]{Value: Cat{XName: "Whiskers"}}
+	pc.Release()
+	if pc.Value != nil {
+		t.Fatalf("want nil value, got %v", pc.Value)
+	}
+}
+
+func BenchmarkContainer_UnmarshalJSONPool(b *testing.B) {
+	raw := []byte(`{"type":"dog","name":"Fido","breed":"Golden Retriever"}`)
+
+	// Without releasing the values the pool stays empty, so every dog is
+	// allocated.
+	b.Run("allocate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var c Container[Animal, *PooledAnimalHelper]
+			if err := c.UnmarshalJSON(raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var c Container[Animal, *PooledAnimalHelper]
+			if err := c.UnmarshalJSON(raw); err != nil {
+				b.Fatal(err)
+			}
+			c.Release()
+		}
+	})
+}
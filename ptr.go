@@ -0,0 +1,71 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// PtrHelper wraps a helper that returns values (e.g. Dog{}) from Get, so that
+// the container stores pointers to the values (e.g. &Dog{}) instead. This
+// allows using the same helper for both storage modes, instead of maintaining
+// a separate helper that returns pointers:
+//
+//	Container[Animal, *AnimalHelper]                     // stores Dog
+//	Container[Animal, *PtrHelper[Animal, *AnimalHelper]] // stores *Dog
+//
+// The pointer type needs to implement V, which is the case if V is an
+// interface and the value implements it. Values that already are pointers are
+// returned as they are. Set receives the dereferenced value, so the wrapped
+// helper only ever sees values. The wrapped helper is marshalled and
+// unmarshalled in place of PtrHelper, and its options are used if it
+// implements OptionsProvider. Other optional interfaces of the wrapped helper
+// are not forwarded.
+type PtrHelper[V any, H Helper[V]] struct {
+	Helper H
+}
+
+// Init allocates the wrapped helper.
+func (p *PtrHelper[V, H]) Init() {
+	// The error is ignored, if H is not a pointer the wrapped helper stays
+	// nil and json.Unmarshal returns an error.
+	p.Helper, _ = newHelper[V, H]()
+}
+
+func (p *PtrHelper[V, H]) Get() V {
+	v := p.Helper.Get()
+	if isNil(v) {
+		return v
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		return v
+	}
+	ptr := reflect.New(val.Type())
+	ptr.Elem().Set(val)
+	if pv, ok := ptr.Interface().(V); ok {
+		return pv
+	}
+	return v
+}
+
+func (p *PtrHelper[V, H]) Set(v V) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		if ev, ok := val.Elem().Interface().(V); ok {
+			v = ev
+		}
+	}
+	p.Helper.Set(v)
+}
+
+func (p *PtrHelper[V, H]) Options() Options {
+	return optionsOf(p.Helper)
+}
+
+func (p *PtrHelper[V, H]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Helper)
+}
+
+func (p *PtrHelper[V, H]) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, p.Helper)
+}
@@ -0,0 +1,78 @@
+package jsonpoly
+
+import (
+	"testing"
+)
+
+// OnlyValuesHelper only accepts values in Set, pointers are not recognized.
+type OnlyValuesHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *OnlyValuesHelper) Set(a Animal) {
+	switch a.(type) {
+	case Dog, Cat:
+		h.Type = a.Type()
+	default:
+		h.Type = "unsupported"
+	}
+}
+
+func TestPtrHelper(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "dog",
+		have: Dog{XName: "Fido", Breed: "Pug"},
+		want: `{"type":"dog","name":"Fido","breed":"Pug"}`,
+	}, {
+		name: "cat",
+		have: Cat{XName: "Whiskers", Owner: "Alice", Color: "White"},
+		want: `{"type":"cat","name":"Whiskers","owner":"Alice","color":"White"}`,
+	}, {
+		name: "dolphin",
+		have: UnknownAnimal{XType: "dolphin", XName: "Cooper"},
+		want: `{"type":"dolphin","name":"Cooper"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name+"_value", func(t *testing.T) {
+			testRoundTrip[Animal, *AnimalContainerHelper](t, tc.have, tc.want)
+		})
+		t.Run(tc.name+"_pointer", func(t *testing.T) {
+			// Same helper, but the container stores a pointer.
+			testRoundTrip[Animal, *PtrHelper[Animal, *AnimalContainerHelper]](t, ptrTo(tc.have), tc.want)
+		})
+	}
+
+	t.Run("set_receives_value", func(t *testing.T) {
+		want := `{"type":"dog","name":"Fido","breed":""}`
+		testRoundTrip[Animal, *PtrHelper[Animal, *OnlyValuesHelper]](t, &Dog{XName: "Fido"}, want)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		want := `{"type":"dog","data":{"name":"Fido","breed":""}}`
+		testRoundTrip[Animal, *PtrHelper[Animal, *EnvelopeAnimalHelper]](t, &Dog{XName: "Fido"}, want)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		if err := ValidateHelper[Animal, *PtrHelper[Animal, *AnimalContainerHelper]](); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// ptrTo returns a pointer to a copy of the animal.
+func ptrTo(a Animal) Animal {
+	switch a := a.(type) {
+	case Dog:
+		return &a
+	case Cat:
+		return &a
+	case UnknownAnimal:
+		return &a
+	}
+	panic("unexpected animal")
+}
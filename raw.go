@@ -0,0 +1,47 @@
+package jsonpoly
+
+import "encoding/json"
+
+// RawValue captures a JSON object of a type that is not known to the helper,
+// so it can be marshalled again without losing any fields. A helper can
+// return it from Get as the fallback for unknown types, setting Discriminator
+// to the value of the discriminator it read. RawValue is usually embedded in
+// a type that implements the interface of the container values:
+//
+//	type UnknownAnimal struct {
+//		jsonpoly.RawValue
+//	}
+//
+//	func (a UnknownAnimal) Name() string { ... }
+//
+// Fields contains all fields of the object, including the discriminator
+// keys. When marshalling, the fields are emitted sorted by key, while the
+// helper still controls the discriminator.
+type RawValue struct {
+	Discriminator string
+	Fields        map[string]json.RawMessage
+}
+
+// Type returns the discriminator of the raw value.
+func (r RawValue) Type() string {
+	return r.Discriminator
+}
+
+// MarshalJSON marshals the captured fields into a JSON object.
+func (r RawValue) MarshalJSON() ([]byte, error) {
+	if r.Fields == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(r.Fields)
+}
+
+// UnmarshalJSON captures the fields of the JSON object, the discriminator is
+// left untouched.
+func (r *RawValue) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	r.Fields = fields
+	return nil
+}
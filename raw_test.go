@@ -0,0 +1,83 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// RawAnimal is the fallback for unknown animals, it preserves all fields.
+type RawAnimal struct {
+	RawValue
+}
+
+func (a RawAnimal) Name() string {
+	var name string
+	_ = json.Unmarshal(a.Fields["name"], &name)
+	return name
+}
+
+type RawAnimalHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *RawAnimalHelper) Get() Animal {
+	if a, ok := KnownAnimals[h.Type]; ok {
+		return a
+	}
+	return RawAnimal{RawValue{Discriminator: h.Type}}
+}
+
+func (h *RawAnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestRawValue(t *testing.T) {
+	have := `{"type":"dolphin","tricks":["jump","spin"],"name":"Cooper","owner":{"name":"Alice"},"age":3}`
+
+	var c Container[Animal, *RawAnimalHelper]
+	if err := json.Unmarshal([]byte(have), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, ok := c.Value.(RawAnimal)
+	if !ok {
+		t.Fatalf("expected RawAnimal, got %T", c.Value)
+	}
+	if raw.Type() != "dolphin" || raw.Name() != "Cooper" {
+		t.Fatalf("unexpected value %+v", raw)
+	}
+	if len(raw.Fields) != 5 {
+		t.Fatalf("expected 5 fields, got %v", raw.Fields)
+	}
+
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"dolphin","age":3,"name":"Cooper","owner":{"name":"Alice"},"tricks":["jump","spin"]}`
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	// Another round trip produces the same output, nothing was lost.
+	testRoundTrip[Animal, *RawAnimalHelper](t, c.Value, want)
+}
+
+func TestRawValue_knownType(t *testing.T) {
+	testRoundTrip[Animal, *RawAnimalHelper](
+		t,
+		Dog{XName: "Fido", Breed: "Pug"},
+		`{"type":"dog","name":"Fido","breed":"Pug"}`,
+	)
+}
+
+func TestRawValue_empty(t *testing.T) {
+	c := New[Animal, *RawAnimalHelper](RawAnimal{RawValue{Discriminator: "dolphin"}})
+	got, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"type":"dolphin"}`; string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,182 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Registry maps discriminator values to the values that should be returned
+// by Helper.Get. It can be used to implement a helper without maintaining a
+// map of known types by hand. The zero value is an empty registry ready to
+// use. A Registry is safe for concurrent use.
+type Registry[V any] struct {
+	mu      sync.RWMutex
+	values  map[string]V
+	aliases map[string]string
+}
+
+// Register adds the value v under key. It panics if the key is already
+// registered, since that is most likely a programming error.
+func (r *Registry[V]) Register(key string, v V) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isRegistered(key) {
+		panic(fmt.Sprintf("jsonpoly: key %q already registered", key))
+	}
+	if r.values == nil {
+		r.values = make(map[string]V)
+	}
+	r.values[key] = v
+}
+
+// RegisterAll registers each of the sample values under the key returned by
+// calling key on the value, which is usually the discriminator method of the
+// interface as a method expression (e.g. Animal.Type). It panics if a key is
+// already registered.
+func (r *Registry[V]) RegisterAll(key func(V) string, samples ...V) {
+	for _, v := range samples {
+		r.Register(key(v), v)
+	}
+}
+
+// RegisterAlias registers alias as a synonym for the canonical key, so that
+// looking up the alias returns the value registered under the canonical key.
+// Aliases are only used when looking up values, the helper should always
+// produce the canonical key when marshalling. It panics if the canonical key
+// is not registered or if the alias is already registered.
+func (r *Registry[V]) RegisterAlias(alias, canonical string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.values[canonical]; !ok {
+		panic(fmt.Sprintf("jsonpoly: canonical key %q not registered", canonical))
+	}
+	if r.isRegistered(alias) {
+		panic(fmt.Sprintf("jsonpoly: key %q already registered", alias))
+	}
+	if r.aliases == nil {
+		r.aliases = make(map[string]string)
+	}
+	r.aliases[alias] = canonical
+}
+
+// Lookup returns the value registered under key. If key is an alias, the
+// value registered under the canonical key is returned.
+func (r *Registry[V]) Lookup(key string) (V, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if canonical, ok := r.aliases[key]; ok {
+		key = canonical
+	}
+	v, ok := r.values[key]
+	return v, ok
+}
+
+// isRegistered reports whether key is registered as a key or an alias.
+func (r *Registry[V]) isRegistered(key string) bool {
+	_, isKey := r.values[key]
+	_, isAlias := r.aliases[key]
+	return isKey || isAlias
+}
+
+// Keys returns all registered keys in sorted order, excluding aliases.
+func (r *Registry[V]) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]string, 0, len(r.values))
+	for k := range r.values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// MultiKeyRegistry is like Registry, but the values are registered under a
+// combination of keys, e.g. a kind and a dimension. It can be used when the
+// type is determined by multiple discriminator fields. The combination is
+// represented by the comparable type K, usually a struct with a field for
+// each discriminator. The zero value is an empty registry ready to use. A
+// MultiKeyRegistry is safe for concurrent use.
+type MultiKeyRegistry[K comparable, V any] struct {
+	mu     sync.RWMutex
+	values map[K]V
+}
+
+// Register adds the value v under the combination of keys. It panics if the
+// combination is already registered, since that is most likely a
+// programming error.
+func (r *MultiKeyRegistry[K, V]) Register(key K, v V) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.values[key]; ok {
+		panic(fmt.Sprintf("jsonpoly: key %+v already registered", key))
+	}
+	if r.values == nil {
+		r.values = make(map[K]V)
+	}
+	r.values[key] = v
+}
+
+// RegisterAll registers each of the sample values under the combination of
+// keys returned by calling key on the value. It panics if a combination is
+// already registered.
+func (r *MultiKeyRegistry[K, V]) RegisterAll(key func(V) K, samples ...V) {
+	for _, v := range samples {
+		r.Register(key(v), v)
+	}
+}
+
+// Lookup returns the value registered under the combination of keys.
+func (r *MultiKeyRegistry[K, V]) Lookup(key K) (V, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	v, ok := r.values[key]
+	return v, ok
+}
+
+// MultiKeyProvider provides the registry and the keys of the values used by
+// MultiKeyHelper. Its methods are called on the zero value of the provider,
+// so the provider is usually an empty struct returning a package-level
+// registry.
+type MultiKeyProvider[K comparable, V any] interface {
+	Registry() *MultiKeyRegistry[K, V]
+	// Key returns the combination of keys of the value.
+	Key(v V) K
+}
+
+// MultiKeyHelper is a Helper for values registered in a MultiKeyRegistry,
+// which removes the need to write a helper when the type is determined by
+// multiple discriminator fields. The fields of K are the discriminator
+// fields, they are marshalled and unmarshalled the same as K itself, so K
+// needs to be a struct with JSON tags (e.g. {"kind":"shape","dimension":2}).
+// If the combination is not registered, unmarshalling returns
+// ErrUnknownType, naming the combination.
+type MultiKeyHelper[K comparable, V any, P MultiKeyProvider[K, V]] struct {
+	Key K
+}
+
+func (h *MultiKeyHelper[K, V, P]) Get() V {
+	var p P
+	v, _ := p.Registry().Lookup(h.Key)
+	return v
+}
+
+func (h *MultiKeyHelper[K, V, P]) Set(v V) {
+	var p P
+	h.Key = p.Key(v)
+}
+
+func (h *MultiKeyHelper[K, V, P]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Key)
+}
+
+func (h *MultiKeyHelper[K, V, P]) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &h.Key)
+}
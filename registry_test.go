@@ -0,0 +1,271 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var animalRegistry = func() *Registry[Animal] {
+	var r Registry[Animal]
+	r.Register("dog", Dog{})
+	r.Register("cat", Cat{})
+	r.RegisterAlias("k9", "dog")
+	return &r
+}()
+
+// AnimalRegistryHelper uses a Registry to look up known animals.
+type AnimalRegistryHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AnimalRegistryHelper) Get() Animal {
+	a, _ := animalRegistry.Lookup(h.Type)
+	return a
+}
+
+func (h *AnimalRegistryHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestRegistry(t *testing.T) {
+	want := Dog{XName: "Fido", Breed: "Golden Retriever"}
+
+	var c Container[Animal, *AnimalRegistryHelper]
+	err := json.Unmarshal([]byte(`{"type":"dog","name":"Fido","breed":"Golden Retriever"}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	if _, ok := animalRegistry.Lookup("dolphin"); ok {
+		t.Fatal("expected dolphin to be unknown")
+	}
+}
+
+func TestRegistry_RegisterAlias(t *testing.T) {
+	want := Dog{XName: "Fido"}
+
+	for _, raw := range []string{
+		`{"type":"dog","name":"Fido"}`,
+		`{"type":"k9","name":"Fido"}`,
+	} {
+		var c Container[Animal, *AnimalRegistryHelper]
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+
+		// The canonical type is always emitted.
+		got, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantJSON := `{"type":"dog","name":"Fido","breed":""}`; string(got) != wantJSON {
+			t.Fatalf("want %s, got %s", wantJSON, string(got))
+		}
+	}
+
+	if got, want := animalRegistry.Keys(), []string{"cat", "dog"}; !slices.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestRegistry_RegisterAliasInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		alias string
+		canon string
+	}{
+		{name: "unknown_canonical", alias: "k9", canon: "wolf"},
+		{name: "alias_is_key", alias: "cat", canon: "dog"},
+		{name: "alias_exists", alias: "pup", canon: "dog"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r Registry[Animal]
+			r.Register("dog", Dog{})
+			r.Register("cat", Cat{})
+			r.RegisterAlias("pup", "dog")
+
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			r.RegisterAlias(tc.alias, tc.canon)
+		})
+	}
+}
+
+func TestRegistry_Keys(t *testing.T) {
+	var r Registry[Animal]
+	if got := r.Keys(); len(got) != 0 {
+		t.Fatalf("want no keys, got %v", got)
+	}
+
+	r.Register("dog", Dog{})
+	r.Register("cat", Cat{})
+	r.Register("bird", Bird{})
+
+	want := []string{"bird", "cat", "dog"}
+	if got := r.Keys(); !slices.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestRegistry_RegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	var r Registry[Animal]
+	r.Register("dog", Dog{})
+	r.Register("dog", Dog{})
+}
+
+var allAnimals = func() *Registry[Animal] {
+	var r Registry[Animal]
+	r.RegisterAll(Animal.Type, Dog{}, Cat{}, Bird{})
+	return &r
+}()
+
+// AllAnimalsHelper looks up animals in a registry built from samples.
+type AllAnimalsHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *AllAnimalsHelper) Get() Animal {
+	a, _ := allAnimals.Lookup(h.Type)
+	return a
+}
+
+func (h *AllAnimalsHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func TestRegistry_RegisterAll(t *testing.T) {
+	want := []string{"bird", "cat", "dog"}
+	if got := allAnimals.Keys(); !slices.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	testCases := []struct {
+		have string
+		want Animal
+	}{
+		{have: `{"type":"dog","name":"Fido"}`, want: Dog{XName: "Fido"}},
+		{have: `{"type":"cat","name":"Whiskers"}`, want: Cat{XName: "Whiskers"}},
+		{have: `{"type":"bird","name":"Tweety"}`, want: Bird{XType: "bird", XName: "Tweety"}},
+	}
+	for _, tc := range testCases {
+		var c Container[Animal, *AllAnimalsHelper]
+		if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != tc.want {
+			t.Fatalf("want %v, got %v", tc.want, c.Value)
+		}
+	}
+}
+
+func TestRegistry_RegisterAllDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	var r Registry[Animal]
+	r.RegisterAll(Animal.Type, Dog{}, Dog{XName: "Rex"})
+}
+
+// animalKey is the key of an animal in a MultiKeyRegistry.
+type animalKey struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+func TestMultiKeyRegistry(t *testing.T) {
+	var r MultiKeyRegistry[animalKey, Animal]
+	r.Register(animalKey{"dog", 1}, Dog{Breed: "Beagle"})
+	r.Register(animalKey{"dog", 2}, Dog{Breed: "Poodle"})
+	r.Register(animalKey{"cat", 2}, Cat{})
+
+	testCases := []struct {
+		key    animalKey
+		want   Animal
+		wantOk bool
+	}{
+		{key: animalKey{"dog", 1}, want: Dog{Breed: "Beagle"}, wantOk: true},
+		{key: animalKey{"dog", 2}, want: Dog{Breed: "Poodle"}, wantOk: true},
+		{key: animalKey{"cat", 2}, want: Cat{}, wantOk: true},
+		{key: animalKey{"cat", 1}, wantOk: false},
+		{key: animalKey{"dog", 0}, wantOk: false},
+		{key: animalKey{"Dog", 1}, wantOk: false},
+	}
+
+	for _, tc := range testCases {
+		got, ok := r.Lookup(tc.key)
+		if ok != tc.wantOk || got != tc.want {
+			t.Fatalf("%v: want %v (ok=%v), got %v (ok=%v)", tc.key, tc.want, tc.wantOk, got, ok)
+		}
+	}
+}
+
+func TestMultiKeyRegistry_RegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	var r MultiKeyRegistry[animalKey, Animal]
+	r.Register(animalKey{"dog", 1}, Dog{})
+	r.Register(animalKey{"dog", 1}, Dog{})
+}
+
+var versionedAnimals = func() *MultiKeyRegistry[animalKey, Animal] {
+	var r MultiKeyRegistry[animalKey, Animal]
+	r.RegisterAll(VersionedAnimals{}.Key, Dog{}, Cat{})
+	return &r
+}()
+
+// VersionedAnimals provides versionedAnimals to MultiKeyHelper, all animals
+// have version 1.
+type VersionedAnimals struct{}
+
+func (VersionedAnimals) Registry() *MultiKeyRegistry[animalKey, Animal] {
+	return versionedAnimals
+}
+
+func (VersionedAnimals) Key(a Animal) animalKey {
+	return animalKey{Type: a.Type(), Version: 1}
+}
+
+func TestMultiKeyHelper(t *testing.T) {
+	type helper = MultiKeyHelper[animalKey, Animal, VersionedAnimals]
+
+	testRoundTrip[Animal, *helper](t, Dog{XName: "Fido"}, `{"type":"dog","version":1,"name":"Fido","breed":""}`)
+	testRoundTrip[Animal, *helper](t, Cat{XName: "Tom"}, `{"type":"cat","version":1,"name":"Tom","owner":"","color":""}`)
+
+	t.Run("unknown", func(t *testing.T) {
+		var c Container[Animal, *helper]
+		err := json.Unmarshal([]byte(`{"type":"dog","version":2,"name":"Fido"}`), &c)
+		if !errors.Is(err, ErrUnknownType) {
+			t.Fatalf("want %v, got %v", ErrUnknownType, err)
+		}
+		if want := `{"type":"dog","version":2}`; !strings.Contains(err.Error(), want) {
+			t.Fatalf("want error naming %s, got %v", want, err)
+		}
+	})
+}
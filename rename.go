@@ -0,0 +1,30 @@
+package jsonpoly
+
+// RenameKeys renames the top-level keys of the JSON object b according to
+// renames, which maps old keys to new keys. Keys are matched exactly, keys not
+// contained in renames and all values are copied as they are. If a key is
+// renamed to a key that already exists in the object, both fields are kept.
+//
+// RenameKeys is meant to be used in PreUnmarshal and PostMarshal hooks of a
+// helper, to normalize field names that differ on the wire (e.g. between
+// versions or regions of a type):
+//
+//	func (h *Helper) PostMarshal(b []byte) ([]byte, error) {
+//		return jsonpoly.RenameKeys(b, map[string]string{"color": "colour"})
+//	}
+//
+//	func (h *Helper) PreUnmarshal(b []byte) ([]byte, error) {
+//		return jsonpoly.RenameKeys(b, map[string]string{"colour": "color"})
+//	}
+func RenameKeys(b []byte, renames map[string]string) ([]byte, error) {
+	fields, err := parseObject(b)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		if k, ok := renames[f.key]; ok {
+			fields[i].key = k
+		}
+	}
+	return writeObject(fields)
+}
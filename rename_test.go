@@ -0,0 +1,107 @@
+package jsonpoly
+
+import (
+	"errors"
+	"testing"
+)
+
+// UKAnimalHelper uses British spelling for the color of cats on the wire.
+type UKAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *UKAnimalHelper) PostMarshal(b []byte) ([]byte, error) {
+	if h.Type != "cat" {
+		return b, nil
+	}
+	return RenameKeys(b, map[string]string{"color": "colour"})
+}
+
+func (h *UKAnimalHelper) PreUnmarshal(b []byte) ([]byte, error) {
+	// The helper is not unmarshalled yet, check the type in the input.
+	typ, _, err := findField(b, "type")
+	if err != nil || string(typ) != `"cat"` {
+		return b, err
+	}
+	return RenameKeys(b, map[string]string{"colour": "color"})
+}
+
+func TestRenameKeys(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    string
+		renames map[string]string
+		want    string
+	}{{
+		name:    "rename",
+		have:    `{"type":"cat","color":"black"}`,
+		renames: map[string]string{"color": "colour"},
+		want:    `{"type":"cat","colour":"black"}`,
+	}, {
+		name:    "swap",
+		have:    `{"a":1,"b":2}`,
+		renames: map[string]string{"a": "b", "b": "a"},
+		want:    `{"b":1,"a":2}`,
+	}, {
+		name:    "nested_unchanged",
+		have:    `{"kitten":{"color":"white"}}`,
+		renames: map[string]string{"color": "colour"},
+		want:    `{"kitten":{"color":"white"}}`,
+	}, {
+		name:    "case_sensitive",
+		have:    `{"Color":"black"}`,
+		renames: map[string]string{"color": "colour"},
+		want:    `{"Color":"black"}`,
+	}, {
+		name:    "escaped",
+		have:    `{"col\u006fr":"black"}`,
+		renames: map[string]string{"color": "colour"},
+		want:    `{"colour":"black"}`,
+	}, {
+		name:    "empty",
+		have:    ` { } `,
+		renames: map[string]string{"color": "colour"},
+		want:    `{}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := RenameKeys([]byte(tc.have), tc.renames)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("not_object", func(t *testing.T) {
+		_, err := RenameKeys([]byte(`["color"]`), map[string]string{"color": "colour"})
+		if !errors.Is(err, ErrNotJSONObject) {
+			t.Fatalf("want %v, got %v", ErrNotJSONObject, err)
+		}
+	})
+}
+
+func TestRenameKeys_hooks(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "cat",
+		have: Cat{XName: "Tom", Color: "grey"},
+		want: `{"type":"cat","name":"Tom","owner":"","colour":"grey"}`,
+	}, {
+		name: "bird",
+		have: Bird{XType: "bird", XName: "Tweety"},
+		want: `{"type":"bird","name":"Tweety"}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testRoundTrip[Animal, *UKAnimalHelper](t, tc.have, tc.want)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package jsonpoly
+
+import "encoding/json"
+
+// Repairer is an optional interface a Helper can implement to fix inputs that
+// can not be unmarshalled into the value, e.g. to tolerate a producer that
+// sends numbers as strings. If unmarshalling the value fails, Repair is
+// called with the JSON object and the error, and the value is unmarshalled
+// again from the returned object. Repair is called at most once per object,
+// if the repaired object can not be unmarshalled either, the new error is
+// returned. If Repair returns an error, that error is returned instead, so
+// returning err signals that the input can not be repaired.
+//
+// The helper is not unmarshalled again, so the repaired object has the same
+// type. Repair is not called for unknown or missing types, nor for values
+// that fail validation. The input stored because of Options.KeepRaw is the
+// original input, not the repaired object.
+type Repairer interface {
+	Repair(raw json.RawMessage, err error) (json.RawMessage, error)
+}
+
+// repairValue asks the helper to repair the object b that failed to
+// unmarshal with err, and unmarshals the value from the repaired object. If
+// the helper does not implement Repairer, err is returned.
+func repairValue[V any](b []byte, err error, helper Helper[V], opts Options) (V, error) {
+	var zero V
+	r, ok := helper.(Repairer)
+	if !ok {
+		return zero, err
+	}
+	if b, err = r.Repair(b, err); err != nil {
+		return zero, err
+	}
+	return unmarshalValue[V](b, helper, opts)
+}
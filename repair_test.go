@@ -0,0 +1,105 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// RepairingAnimalHelper repairs ages that are sent as strings.
+type RepairingAnimalHelper struct {
+	ValidatedAnimalHelper
+
+	// repairs counts the calls to Repair, it is passed as the context, so
+	// each test counts its own calls.
+	repairs *int
+}
+
+func (h *RepairingAnimalHelper) SetContext(ctx any) {
+	h.repairs, _ = ctx.(*int)
+}
+
+func (h *RepairingAnimalHelper) Repair(raw json.RawMessage, err error) (json.RawMessage, error) {
+	if h.repairs != nil {
+		*h.repairs++
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	var age string
+	if json.Unmarshal(fields["age"], &age) != nil {
+		// Not a string, we can't repair it.
+		return nil, err
+	}
+	fields["age"] = json.RawMessage(age)
+	if _, convErr := strconv.Atoi(age); convErr != nil {
+		// Not a number either, the retry fails.
+		fields["age"] = json.RawMessage(strconv.Quote(age))
+	}
+	return json.Marshal(fields)
+}
+
+func TestRepairer(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    string
+		want    Animal
+		wantErr bool
+		repairs int
+	}{{
+		name:    "valid",
+		have:    `{"type":"hamster","name":"Hammy","age":3}`,
+		want:    Hamster{XName: "Hammy", Age: 3},
+		repairs: 0,
+	}, {
+		name:    "string_number",
+		have:    `{"type":"hamster","name":"Hammy","age":"3"}`,
+		want:    Hamster{XName: "Hammy", Age: 3},
+		repairs: 1,
+	}, {
+		name:    "not_repairable",
+		have:    `{"type":"hamster","name":"Hammy","age":true}`,
+		wantErr: true,
+		repairs: 1,
+	}, {
+		name:    "retry_fails",
+		have:    `{"type":"hamster","name":"Hammy","age":"three"}`,
+		wantErr: true,
+		repairs: 1,
+	}, {
+		name:    "unknown_type",
+		have:    `{"type":"dog","name":"Fido"}`,
+		wantErr: true,
+		repairs: 0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var repairs int
+			var c Container[Animal, *RepairingAnimalHelper]
+			err := c.UnmarshalWithContext(&repairs, []byte(tc.have))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if c.Value != tc.want {
+				t.Fatalf("want %v, got %v", tc.want, c.Value)
+			}
+			if repairs != tc.repairs {
+				t.Fatalf("want %d repairs, got %d", tc.repairs, repairs)
+			}
+		})
+	}
+
+	t.Run("validation_error", func(t *testing.T) {
+		var c Container[Animal, *RepairingAnimalHelper]
+		err := c.UnmarshalJSON([]byte(`{"type":"hamster","name":"Hammy","age":"-1"}`))
+		if !errors.Is(err, errNegativeAge) {
+			t.Fatalf("want %v, got %v", errNegativeAge, err)
+		}
+		if want := (Hamster{XName: "Hammy", Age: -1}); c.Value != want {
+			t.Fatalf("want %v, got %v", want, c.Value)
+		}
+	})
+}
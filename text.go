@@ -0,0 +1,56 @@
+package jsonpoly
+
+// TextHelper is an optional interface a Helper can implement to support text
+// marshalling through TextContainer, e.g. for values that can be represented
+// as a string like "dog:Fido". This allows using containers as map keys in
+// JSON objects or as URL parameters.
+type TextHelper[V any] interface {
+	// FormatText returns the text representation of the value.
+	FormatText(v V) ([]byte, error)
+	// ParseText returns the value represented by the text.
+	ParseText(text []byte) (V, error)
+}
+
+// TextContainer is a Container that additionally implements
+// encoding.TextMarshaler and encoding.TextUnmarshaler using the helper. The
+// text methods are only available on this type, so that libraries preferring
+// text marshalling (e.g. log/slog) keep using JSON for plain containers. It is
+// still marshalled as a JSON object by encoding/json, except when used as a
+// map key.
+type TextContainer[V any, H interface {
+	Helper[V]
+	TextHelper[V]
+}] struct {
+	Container[V, H]
+}
+
+// MarshalText marshals the value into text using the helper. A nil value is
+// marshalled as empty text.
+func (c TextContainer[V, H]) MarshalText() ([]byte, error) {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return nil, err
+	}
+	if isNil(c.Value) {
+		return []byte{}, nil
+	}
+	return helper.FormatText(c.Value)
+}
+
+// UnmarshalText unmarshals the text into the value using the helper. Empty
+// text is unmarshalled into the zero value.
+func (c *TextContainer[V, H]) UnmarshalText(text []byte) error {
+	helper, err := newHelper[V, H]()
+	if err != nil {
+		return err
+	}
+
+	var v V
+	if len(text) > 0 {
+		if v, err = helper.ParseText(text); err != nil {
+			return err
+		}
+	}
+	c.Value, c.ID, c.raw = v, "", ""
+	return nil
+}
@@ -0,0 +1,139 @@
+package jsonpoly
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TextAnimalHelper represents animals as text in the form "type:name".
+type TextAnimalHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *TextAnimalHelper) FormatText(a Animal) ([]byte, error) {
+	return []byte(a.Type() + ":" + a.Name()), nil
+}
+
+func (h *TextAnimalHelper) ParseText(text []byte) (Animal, error) {
+	typ, name, ok := strings.Cut(string(text), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid animal %q", text)
+	}
+	switch typ {
+	case "dog":
+		return Dog{XName: name}, nil
+	case "cat":
+		return Cat{XName: name}, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownType, typ)
+}
+
+// newTextAnimal returns a TextContainer holding the animal.
+func newTextAnimal(a Animal) TextContainer[Animal, *TextAnimalHelper] {
+	return TextContainer[Animal, *TextAnimalHelper]{Container: New[Animal, *TextAnimalHelper](a)}
+}
+
+func TestTextContainer_MarshalText(t *testing.T) {
+	testCases := []struct {
+		name string
+		have Animal
+		want string
+	}{{
+		name: "dog",
+		have: Dog{XName: "Fido"},
+		want: "dog:Fido",
+	}, {
+		name: "cat",
+		have: Cat{XName: "Tom"},
+		want: "cat:Tom",
+	}, {
+		name: "nil",
+		have: nil,
+		want: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newTextAnimal(tc.have).MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+
+			var c TextContainer[Animal, *TextAnimalHelper]
+			if err := c.UnmarshalText(got); err != nil {
+				t.Fatal(err)
+			}
+			if c.Value != tc.have {
+				t.Fatalf("want %v, got %v", tc.have, c.Value)
+			}
+		})
+	}
+}
+
+func TestTextContainer_MarshalText_mapKey(t *testing.T) {
+	have := map[TextContainer[Animal, *TextAnimalHelper]]int{
+		newTextAnimal(Dog{XName: "Fido"}): 3,
+		newTextAnimal(Cat{XName: "Tom"}):  5,
+	}
+	want := `{"cat:Tom":5,"dog:Fido":3}`
+
+	got, err := json.Marshal(have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	var m map[TextContainer[Animal, *TextAnimalHelper]]int
+	if err := json.Unmarshal(got, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m[newTextAnimal(Dog{XName: "Fido"})] != 3 || len(m) != 2 {
+		t.Fatalf("want %v, got %v", have, m)
+	}
+}
+
+func TestTextContainer_MarshalText_error(t *testing.T) {
+	var c TextContainer[Animal, *TextAnimalHelper]
+	if err := c.UnmarshalText([]byte("dolphin:Cooper")); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("want %v, got %v", ErrUnknownType, err)
+	}
+}
+
+func TestTextContainer_json(t *testing.T) {
+	// Values are still marshalled as JSON objects.
+	got, err := json.Marshal(newTextAnimal(Dog{XName: "Fido"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"type":"dog","name":"Fido","breed":""}`; string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	var c TextContainer[Animal, *TextAnimalHelper]
+	if err := json.Unmarshal(got, &c); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Dog{XName: "Fido"}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+}
+
+func TestContainer_notTextMarshaler(t *testing.T) {
+	// Plain containers don't implement the text interfaces, so libraries
+	// preferring text marshalling use JSON instead.
+	if _, ok := any(Container[Animal, *TextAnimalHelper]{}).(encoding.TextMarshaler); ok {
+		t.Fatal("want Container not to implement encoding.TextMarshaler")
+	}
+	if _, ok := any(&Container[Animal, *TextAnimalHelper]{}).(encoding.TextUnmarshaler); ok {
+		t.Fatal("want *Container not to implement encoding.TextUnmarshaler")
+	}
+}
@@ -0,0 +1,42 @@
+package jsonpoly
+
+// Format converts documents between some encoding format and JSON. It is
+// used by Transcode to read and write formats other than JSON, while the
+// discriminator is still resolved by the helper on the JSON representation.
+type Format interface {
+	// ToJSON converts a document in this format to JSON.
+	ToJSON(b []byte) ([]byte, error)
+	// FromJSON converts a JSON document to this format.
+	FromJSON(b []byte) ([]byte, error)
+}
+
+// JSON is the Format for plain JSON documents, it leaves documents as they
+// are.
+var JSON Format = jsonFormat{}
+
+type jsonFormat struct{}
+
+func (jsonFormat) ToJSON(b []byte) ([]byte, error)   { return b, nil }
+func (jsonFormat) FromJSON(b []byte) ([]byte, error) { return b, nil }
+
+// Transcode reads a polymorphic object encoded in format from and writes it
+// encoded in format to. The object is decoded into a Container[V, H] and
+// encoded again, so the output contains the fields as produced by the helper
+// and the concrete value, regardless of the field order in the input.
+func Transcode[V any, H Helper[V]](b []byte, from, to Format) ([]byte, error) {
+	b, err := from.ToJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Container[V, H]
+	if err := c.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	b, err = c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return to.FromJSON(b)
+}
@@ -0,0 +1,61 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// indentFormat is a Format that writes indented JSON, used to test that the
+// output format is applied.
+type indentFormat struct{}
+
+func (indentFormat) ToJSON(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (indentFormat) FromJSON(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func TestTranscode(t *testing.T) {
+	have := `{"name":"Fido","type":"dog","breed":"Pug"}`
+	want := "{\n  \"type\": \"dog\",\n  \"name\": \"Fido\",\n  \"breed\": \"Pug\"\n}"
+
+	got, err := Transcode[Animal, *AnimalContainerHelper]([]byte(have), JSON, indentFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	got, err = Transcode[Animal, *AnimalContainerHelper](got, indentFormat{}, JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"type":"dog","name":"Fido","breed":"Pug"}`; string(got) != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
+
+func TestTranscode_error(t *testing.T) {
+	_, err := Transcode[Animal, *AnimalContainerHelper]([]byte(`[]`), JSON, JSON)
+	if !errors.Is(err, ErrNotJSONObject) {
+		t.Fatalf("expected ErrNotJSONObject, got %v", err)
+	}
+
+	_, err = Transcode[Animal, *AnimalContainerHelper]([]byte(`{`), indentFormat{}, JSON)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
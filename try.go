@@ -0,0 +1,124 @@
+package jsonpoly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// CandidateProvider provides the candidate types for a TryContainer. It is
+// called on a newly allocated provider, the same as Helper.Get.
+type CandidateProvider[V any] interface {
+	// Candidates returns a value of each candidate type in the order in
+	// which they should be tried. Values can be pointers, in which case the
+	// container stores pointers.
+	Candidates() []V
+}
+
+// TryContainer is a fallback for polymorphic JSON objects without a
+// discriminator. When unmarshalling, the object is unmarshalled into each
+// candidate type returned by P in order, and the first candidate that
+// succeeds is stored in Value. A candidate succeeds if the object does not
+// contain any fields unknown to the candidate and if the candidate passes
+// validation, in case it implements Validator and P implements
+// OptionsProvider enabling Options.Validate. Other options are ignored.
+// Candidates implementing
+// json.Unmarshaler need to reject unknown fields themselves.
+//
+// Since candidates are not required to contain all fields, an object can
+// match multiple candidates (e.g. if it only contains fields shared by
+// multiple types), in which case the first one wins. Candidates should
+// therefore be ordered from the most to the least specific. If no candidate
+// succeeds, the returned error wraps ErrNoCandidate and the errors of all
+// candidates.
+//
+// The value is marshalled as it is, without adding any fields.
+type TryContainer[V any, P CandidateProvider[V]] struct {
+	Value V
+}
+
+// MarshalJSON marshals the value, a nil value is marshalled as null.
+func (c TryContainer[V, P]) MarshalJSON() ([]byte, error) {
+	if isNil(c.Value) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(c.Value)
+}
+
+// UnmarshalJSON unmarshals the object into the first matching candidate. If
+// the input is null, Value is set to the zero value, the same as in
+// Container.UnmarshalJSON.
+func (c *TryContainer[V, P]) UnmarshalJSON(b []byte) error {
+	if string(bytes.TrimSpace(b)) == "null" {
+		var zero V
+		c.Value = zero
+		return nil
+	}
+
+	p, err := newCandidateProvider[V, P]()
+	if err != nil {
+		return err
+	}
+
+	candidates := p.Candidates()
+	errs := make([]error, 0, len(candidates))
+	for _, cand := range candidates {
+		if isNil(cand) {
+			continue
+		}
+		v, err := tryCandidate(b, cand, optionsOf(p))
+		if err == nil {
+			c.Value = v
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%T: %w", cand, err))
+	}
+	return fmt.Errorf("%w: %w", ErrNoCandidate, errors.Join(errs...))
+}
+
+// newCandidateProvider allocates a new provider, the same as newHelper.
+func newCandidateProvider[V any, P CandidateProvider[V]]() (P, error) {
+	t := reflect.TypeFor[P]()
+	if t.Kind() != reflect.Ptr {
+		var zero P
+		return zero, ErrHelperNotPointer
+	}
+	return reflect.New(t.Elem()).Interface().(P), nil
+}
+
+// tryCandidate unmarshals the object into a copy of the candidate, rejecting
+// unknown fields, and validates the result.
+func tryCandidate[V any](b []byte, cand V, opts Options) (V, error) {
+	var zero V
+	val := reflect.ValueOf(cand)
+
+	// The candidate is copied, so it is not modified if it's a pointer and
+	// does not keep fields of previous objects.
+	var ptr reflect.Value
+	if val.Kind() == reflect.Ptr {
+		ptr = reflect.New(val.Type().Elem())
+		ptr.Elem().Set(val.Elem())
+	} else {
+		ptr = reflect.New(val.Type())
+		ptr.Elem().Set(val)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(ptr.Interface()); err != nil {
+		return zero, err
+	}
+
+	var v V
+	if val.Kind() == reflect.Ptr {
+		v = ptr.Interface().(V)
+	} else {
+		v = ptr.Elem().Interface().(V)
+	}
+	if err := validate(v, opts); err != nil {
+		return zero, err
+	}
+	return v, nil
+}
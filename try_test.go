@@ -0,0 +1,141 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// AnimalCandidates tries dogs first, then cats and hamsters.
+type AnimalCandidates struct{}
+
+func (*AnimalCandidates) Candidates() []Animal {
+	return []Animal{Dog{}, Cat{}, Hamster{}}
+}
+
+func (*AnimalCandidates) Options() Options {
+	return Options{Validate: true}
+}
+
+// UnvalidatedCandidates only tries hamsters, without validating them.
+type UnvalidatedCandidates struct{}
+
+func (*UnvalidatedCandidates) Candidates() []Animal {
+	return []Animal{Hamster{}}
+}
+
+// AnimalPtrCandidates returns pointers, the shared candidates must not be
+// modified.
+type AnimalPtrCandidates struct{}
+
+var catCandidate = &Cat{Color: "unknown"}
+
+func (*AnimalPtrCandidates) Candidates() []Animal {
+	return []Animal{catCandidate}
+}
+
+func TestTryContainer(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		want Animal
+	}{{
+		name: "dog",
+		have: `{"name":"Fido","breed":"Pug"}`,
+		want: Dog{XName: "Fido", Breed: "Pug"},
+	}, {
+		name: "cat",
+		have: `{"name":"Tom","color":"grey"}`,
+		want: Cat{XName: "Tom", Color: "grey"},
+	}, {
+		name: "hamster",
+		have: `{"name":"Hammy","age":2}`,
+		want: Hamster{XName: "Hammy", Age: 2},
+	}, {
+		// Only the name is set, dogs and cats both match, the first one
+		// wins.
+		name: "ambiguous",
+		have: `{"name":"Rex"}`,
+		want: Dog{XName: "Rex"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c TryContainer[Animal, *AnimalCandidates]
+			if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+				t.Fatal(err)
+			}
+			if c.Value != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, c.Value)
+			}
+
+			got, err := json.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var roundTrip TryContainer[Animal, *AnimalCandidates]
+			if err := json.Unmarshal(got, &roundTrip); err != nil {
+				t.Fatal(err)
+			}
+			if roundTrip.Value != tc.want {
+				t.Fatalf("want %#v after round trip, got %#v", tc.want, roundTrip.Value)
+			}
+		})
+	}
+
+	t.Run("no_candidate", func(t *testing.T) {
+		var c TryContainer[Animal, *AnimalCandidates]
+		// The hamster fails validation, the others have unknown fields.
+		err := json.Unmarshal([]byte(`{"name":"Hammy","age":-1}`), &c)
+		if !errors.Is(err, ErrNoCandidate) {
+			t.Fatalf("want %v, got %v", ErrNoCandidate, err)
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !errors.Is(err, errNegativeAge) {
+			t.Fatalf("want validation error of the hamster, got %v", err)
+		}
+		for _, typ := range []string{"jsonpoly.Dog", "jsonpoly.Cat", "jsonpoly.Hamster"} {
+			if !strings.Contains(err.Error(), typ) {
+				t.Fatalf("want error of %s, got %v", typ, err)
+			}
+		}
+		if c.Value != nil {
+			t.Fatalf("want nil value, got %v", c.Value)
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		c := TryContainer[Animal, *AnimalCandidates]{Value: Dog{XName: "Fido"}}
+		if err := json.Unmarshal([]byte(`null`), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Value != nil {
+			t.Fatalf("want nil value, got %#v", c.Value)
+		}
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		var c TryContainer[Animal, *AnimalPtrCandidates]
+		if err := json.Unmarshal([]byte(`{"name":"Tom"}`), &c); err != nil {
+			t.Fatal(err)
+		}
+		want := Cat{XName: "Tom", Color: "unknown"}
+		if got, ok := c.Value.(*Cat); !ok || *got != want {
+			t.Fatalf("want %#v, got %#v", &want, c.Value)
+		}
+		if *catCandidate != (Cat{Color: "unknown"}) {
+			t.Fatalf("candidate was modified: %#v", catCandidate)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		got, err := json.Marshal(TryContainer[Animal, *AnimalCandidates]{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "null" {
+			t.Fatalf("want null, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,12 @@
+package jsonpoly
+
+// TypedUnmarshaler is an optional interface a value can implement if it needs
+// the discriminator to unmarshal itself, e.g. to decide how to decode some of
+// its fields based on the type. It is used instead of json.Unmarshaler and
+// Options.Codec. The value receives the whole JSON object and the
+// discriminator as a string. If the helper does not have a single string
+// field, typeName is the JSON object produced by the helper (e.g.
+// {"kind":"shape","dimension":2}).
+type TypedUnmarshaler interface {
+	UnmarshalJSONWithType(b []byte, typeName string) error
+}
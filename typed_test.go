@@ -0,0 +1,115 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Parrot uses the type to decode itself, legacy parrots store the name in a
+// different field.
+type Parrot struct {
+	XName  string `json:"name"`
+	Legacy bool   `json:"-"`
+}
+
+func (p Parrot) Type() string {
+	if p.Legacy {
+		return "parrot-legacy"
+	}
+	return "parrot"
+}
+func (p Parrot) Name() string {
+	return p.XName
+}
+
+func (p *Parrot) UnmarshalJSONWithType(b []byte, typeName string) error {
+	if typeName != "parrot-legacy" {
+		type parrot Parrot // Prevent recursion.
+		return json.Unmarshal(b, (*parrot)(p))
+	}
+	var legacy struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
+	}
+	p.XName, p.Legacy = legacy.Title, true
+	return nil
+}
+
+type ParrotHelper struct {
+	AnimalContainerHelper
+}
+
+func (h *ParrotHelper) Get() Animal {
+	switch h.Type {
+	case "parrot", "parrot-legacy":
+		return Parrot{}
+	case "parrot-ptr":
+		return &Parrot{}
+	}
+	return h.AnimalContainerHelper.Get()
+}
+
+func TestTypedUnmarshaler(t *testing.T) {
+	testCases := []struct {
+		name string
+		have string
+		want Animal
+	}{{
+		name: "current",
+		have: `{"type":"parrot","name":"Polly","title":"ignored"}`,
+		want: Parrot{XName: "Polly"},
+	}, {
+		name: "legacy",
+		have: `{"type":"parrot-legacy","name":"ignored","title":"Polly"}`,
+		want: Parrot{XName: "Polly", Legacy: true},
+	}, {
+		name: "pointer",
+		have: `{"type":"parrot-ptr","name":"Polly"}`,
+		want: &Parrot{XName: "Polly"},
+	}, {
+		name: "other",
+		have: `{"type":"dog","name":"Fido"}`,
+		want: Dog{XName: "Fido"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Container[Animal, *ParrotHelper]
+			if err := json.Unmarshal([]byte(tc.have), &c); err != nil {
+				t.Fatal(err)
+			}
+			if p, ok := tc.want.(*Parrot); ok {
+				if got, ok := c.Value.(*Parrot); !ok || *got != *p {
+					t.Fatalf("want %#v, got %#v", p, c.Value)
+				}
+				return
+			}
+			if c.Value != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, c.Value)
+			}
+		})
+	}
+
+	t.Run("multiple_keys", func(t *testing.T) {
+		var p typeRecorder
+		if err := unmarshalInto([]byte(`{}`), &p, &struct {
+			Kind      string `json:"kind"`
+			Dimension int    `json:"dimension"`
+		}{Kind: "shape", Dimension: 2}, Options{}); err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"kind":"shape","dimension":2}`; string(p) != want {
+			t.Fatalf("want %s, got %s", want, p)
+		}
+	})
+}
+
+// typeRecorder records the type name it was unmarshalled with.
+type typeRecorder string
+
+func (r *typeRecorder) UnmarshalJSONWithType(_ []byte, typeName string) error {
+	*r = typeRecorder(typeName)
+	return nil
+}
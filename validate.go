@@ -0,0 +1,39 @@
+package jsonpoly
+
+import "fmt"
+
+// Validator is an optional interface a value can implement to validate itself
+// after it was unmarshalled. Values are only validated if the helper enables
+// Options.Validate. If Validate returns an error, unmarshalling fails with a
+// *ValidationError wrapping it.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError is returned when an unmarshalled value fails validation.
+// Contrary to other errors, the value is populated on a validation failure,
+// so it can still be inspected (e.g. for logging).
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validation failed: %v", e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validate runs the validation of v if it implements Validator and the
+// options enable validation.
+func validate(v any, opts Options) error {
+	val, ok := v.(Validator)
+	if !ok || !opts.Validate {
+		return nil
+	}
+	if err := val.Validate(); err != nil {
+		return &ValidationError{Err: err}
+	}
+	return nil
+}
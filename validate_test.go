@@ -0,0 +1,138 @@
+package jsonpoly
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var errNegativeAge = errors.New("age must not be negative")
+
+type Hamster struct {
+	XName string `json:"name"`
+	Age   int    `json:"age"`
+}
+
+func (Hamster) Type() string {
+	return "hamster"
+}
+func (h Hamster) Name() string {
+	return h.XName
+}
+func (h Hamster) Validate() error {
+	if h.Age < 0 {
+		return errNegativeAge
+	}
+	return nil
+}
+
+type ValidatedAnimalHelper struct {
+	Type string `json:"type"`
+}
+
+func (h *ValidatedAnimalHelper) Get() Animal {
+	if h.Type == "hamster" {
+		return Hamster{}
+	}
+	return nil
+}
+
+func (h *ValidatedAnimalHelper) Set(a Animal) {
+	h.Type = a.Type()
+}
+
+func (h *ValidatedAnimalHelper) Options() Options {
+	return Options{Validate: true}
+}
+
+func TestContainer_validate(t *testing.T) {
+	var c Container[Animal, *ValidatedAnimalHelper]
+	err := json.Unmarshal([]byte(`{"type":"hamster","name":"Bob","age":2}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Hamster{XName: "Bob", Age: 2}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	c = Container[Animal, *ValidatedAnimalHelper]{}
+	err = json.Unmarshal([]byte(`{"type":"hamster","name":"Bob","age":-1}`), &c)
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if !errors.Is(err, errNegativeAge) {
+		t.Fatalf("expected errNegativeAge, got %v", err)
+	}
+	// The value is populated even though it failed validation.
+	if want := (Hamster{XName: "Bob", Age: -1}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+}
+
+// UnvalidatedAnimalHelper does not enable validation.
+type UnvalidatedAnimalHelper struct {
+	ValidatedAnimalHelper
+}
+
+func (h *UnvalidatedAnimalHelper) Options() Options {
+	return Options{}
+}
+
+func TestContainer_validateDisabled(t *testing.T) {
+	var c Container[Animal, *UnvalidatedAnimalHelper]
+	if err := json.Unmarshal([]byte(`{"type":"hamster","name":"Bob","age":-1}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Hamster{XName: "Bob", Age: -1}); c.Value != want {
+		t.Fatalf("want %v, got %v", want, c.Value)
+	}
+
+	var tc TryContainer[Animal, *UnvalidatedCandidates]
+	if err := json.Unmarshal([]byte(`{"name":"Bob","age":-1}`), &tc); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Hamster{XName: "Bob", Age: -1}); tc.Value != want {
+		t.Fatalf("want %v, got %v", want, tc.Value)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	testCases := []struct {
+		name    string
+		have    string
+		want    Animal
+		wantErr error
+	}{{
+		name: "valid",
+		have: `{"type":"hamster","name":"Bob","age":2}`,
+		want: Hamster{XName: "Bob", Age: 2},
+	}, {
+		name:    "invalid",
+		have:    `{"type":"hamster","name":"Bob","age":-1}`,
+		want:    Hamster{XName: "Bob", Age: -1},
+		wantErr: errNegativeAge,
+	}, {
+		name:    "unknown_type",
+		have:    `{"type":"dog","name":"Bob"}`,
+		want:    nil,
+		wantErr: ErrUnknownType,
+	}, {
+		name:    "not_object",
+		have:    `[]`,
+		want:    nil,
+		wantErr: ErrNotJSONObject,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Unmarshal[Animal, *ValidatedAnimalHelper]([]byte(tc.have))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}